@@ -0,0 +1,312 @@
+// Package audit writes a tamper-evident record of every decision as JSON lines
+//
+// Every line is an Entry holding a Record and a hash chaining it to the previous line:
+// hash = hex(hmac-sha256(key, prevHash + record)), where record is the JSON of the Record as written on the line.
+// Editing, removing or reordering lines breaks the chain, which Verify detects.
+// Without a key the hash is a plain sha256, which anyone able to write the log can recompute:
+// the chain then only detects accidental damage.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	k8s "github.com/andreistefanciprian/pod-restarter-go/kubernetes"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Stdout is the path that writes the audit log to stdout, which is never rotated
+const Stdout = "-"
+
+// Decisions
+const (
+	DecisionRemediate = "remediate"
+	DecisionSkip      = "skip"
+	DecisionDefer     = "defer"
+	DecisionError     = "error"
+)
+
+// maxLineSize is the size of the longest line read when resuming or verifying an audit log
+const maxLineSize = 4 * 1024 * 1024
+
+// Record is the decision taken about a Pod matched by a rule, and its evidence
+// Response is "OK" when the action succeeded, or the error returned by the API server
+type Record struct {
+	Seq       uint64            `json:"seq"`
+	Time      time.Time         `json:"time"`
+	Cluster   string            `json:"cluster,omitempty"`
+	Namespace string            `json:"namespace"`
+	Pod       string            `json:"pod"`
+	UID       types.UID         `json:"uid,omitempty"`
+	Owner     string            `json:"owner,omitempty"`
+	Node      string            `json:"node,omitempty"`
+	Rule      string            `json:"rule"`
+	Events    []k8s.PodEvent    `json:"events"`
+	Checks    []k8s.CheckResult `json:"checks,omitempty"`
+	Decision  string            `json:"decision"`
+	Reason    string            `json:"reason,omitempty"`
+	Action    string            `json:"action"`
+	Plan      *k8s.Plan         `json:"plan,omitempty"`
+	DryRun    bool              `json:"dryRun"`
+	Outcome   string            `json:"outcome,omitempty"`
+	Response  string            `json:"response,omitempty"`
+}
+
+// Entry is a line of the audit log
+type Entry struct {
+	PrevHash string          `json:"prevHash"`
+	Hash     string          `json:"hash"`
+	Record   json.RawMessage `json:"record"`
+}
+
+// Log appends records to a file, rotated once it reaches maxSize bytes, or to stdout
+// A nil Log drops the records
+type Log struct {
+	mu         sync.Mutex
+	path       string
+	w          io.Writer
+	file       *os.File
+	size       int64
+	maxSize    int64
+	maxBackups int
+	key        []byte
+	seq        uint64
+	lastHash   string
+}
+
+// Open returns a Log appending to path, or to stdout when path is Stdout, whose lines are chained with key (nil for no key)
+// The hash chain and sequence of an existing file are resumed
+// Once the file would grow past maxSize bytes (0 disables rotation) it is renamed to path.1, path.1 to path.2 and so on,
+// keeping maxBackups rotated files
+func Open(path string, maxSize int64, maxBackups int, key []byte) (*Log, error) {
+	if maxSize < 0 || maxBackups < 0 {
+		return nil, errors.New("audit log max size and max backups must not be negative")
+	}
+	l := &Log{path: path, maxSize: maxSize, maxBackups: maxBackups, key: key}
+	if path == Stdout {
+		l.w = os.Stdout
+		return l, nil
+	}
+
+	err := l.resume()
+	if err != nil {
+		return nil, err
+	}
+	err = l.open()
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Write sets the sequence number of rec and appends it to the log
+func (l *Log) Write(rec Record) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	rec.Seq = l.seq + 1
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("Could not encode audit record: %w", err)
+	}
+	entry := Entry{PrevHash: l.lastHash, Hash: chainHash(l.key, l.lastHash, data), Record: data}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("Could not encode audit record: %w", err)
+	}
+	line = append(line, '\n')
+
+	if l.file != nil && l.maxSize > 0 && l.size > 0 && l.size+int64(len(line)) > l.maxSize {
+		err = l.rotate()
+		if err != nil {
+			return err
+		}
+	}
+	n, err := l.w.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("Could not write audit record: %w", err)
+	}
+	l.seq = rec.Seq
+	l.lastHash = entry.Hash
+	return nil
+}
+
+// Close closes the audit log file
+func (l *Log) Close() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+// resume reads the sequence number and hash of the last line of an existing audit log
+func (l *Log) resume() error {
+	f, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Could not open audit log: %w", err)
+	}
+	defer f.Close()
+
+	var last []byte
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, maxLineSize)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) > 0 {
+			last = append(last[:0], scanner.Bytes()...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("Could not read audit log %s: %w", l.path, err)
+	}
+	if last == nil {
+		return nil
+	}
+
+	var entry Entry
+	var rec struct {
+		Seq uint64 `json:"seq"`
+	}
+	err = json.Unmarshal(last, &entry)
+	if err == nil {
+		err = json.Unmarshal(entry.Record, &rec)
+	}
+	if err != nil {
+		return fmt.Errorf("Could not resume audit log %s: %w", l.path, err)
+	}
+	l.seq = rec.Seq
+	l.lastHash = entry.Hash
+	return nil
+}
+
+// open opens the audit log file for appending
+func (l *Log) open() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("Could not open audit log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("Could not open audit log: %w", err)
+	}
+	l.file = f
+	l.w = f
+	l.size = info.Size()
+	return nil
+}
+
+// rotate shifts the rotated files, renames the audit log to path.1 and opens a new one
+// The oldest rotated file is removed once there are maxBackups of them
+func (l *Log) rotate() error {
+	err := l.file.Close()
+	if err != nil {
+		return fmt.Errorf("Could not rotate audit log: %w", err)
+	}
+	if l.maxBackups == 0 {
+		err = os.Remove(l.path)
+	} else {
+		for i := l.maxBackups - 1; i >= 1; i-- {
+			err = os.Rename(backup(l.path, i), backup(l.path, i+1))
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("Could not rotate audit log: %w", err)
+			}
+		}
+		err = os.Rename(l.path, backup(l.path, 1))
+	}
+	if err != nil {
+		return fmt.Errorf("Could not rotate audit log: %w", err)
+	}
+	return l.open()
+}
+
+// backup returns the path of the i-th rotated file
+func backup(path string, i int) string {
+	return fmt.Sprintf("%s.%d", path, i)
+}
+
+// chainHash returns the hash of record chained to prevHash, keyed with key unless it is empty
+func chainHash(key []byte, prevHash string, record []byte) string {
+	h := sha256.New()
+	if len(key) > 0 {
+		h = hmac.New(sha256.New, key)
+	}
+	h.Write([]byte(prevHash))
+	h.Write(record)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Anchor returns the hash the chain of the oldest audit log file read from r starts at
+// It is "" when the file starts with the first record, or the previous hash of its first line when older files were
+// removed by rotation
+func Anchor(r io.Reader) (string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineSize)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var entry Entry
+		var rec struct {
+			Seq uint64 `json:"seq"`
+		}
+		err := json.Unmarshal(scanner.Bytes(), &entry)
+		if err == nil {
+			err = json.Unmarshal(entry.Record, &rec)
+		}
+		if err != nil {
+			return "", fmt.Errorf("line %d: %w", line, err)
+		}
+		if rec.Seq == 1 {
+			return "", nil
+		}
+		return entry.PrevHash, nil
+	}
+	return "", scanner.Err()
+}
+
+// Verify checks the hash chain of the audit log read from r, keyed with key and starting at prevHash (see Anchor)
+// It returns the hash of the last line, to verify the next (more recent) file, or the line where the chain breaks
+func Verify(r io.Reader, prevHash string, key []byte) (string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineSize)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var entry Entry
+		err := json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			return prevHash, fmt.Errorf("line %d: %w", line, err)
+		}
+		if entry.PrevHash != prevHash {
+			return prevHash, fmt.Errorf("line %d: previous hash %q does not match %q", line, entry.PrevHash, prevHash)
+		}
+		if hash := chainHash(key, entry.PrevHash, entry.Record); entry.Hash != hash {
+			return prevHash, fmt.Errorf("line %d: hash %q does not match the record", line, entry.Hash)
+		}
+		prevHash = entry.Hash
+	}
+	if err := scanner.Err(); err != nil {
+		return prevHash, err
+	}
+	return prevHash, nil
+}
@@ -0,0 +1,260 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	k8s "github.com/andreistefanciprian/pod-restarter-go/kubernetes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var testNow = time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+
+var testKey = []byte("s3cr3t")
+
+func testRecord(pod string) Record {
+	return Record{
+		Time:      testNow,
+		Namespace: "default",
+		Pod:       pod,
+		UID:       types.UID("uid-" + pod),
+		Owner:     "ReplicaSet/" + pod + "-rs",
+		Rule:      "veth",
+		Events: []k8s.PodEvent{{
+			PodName:       pod,
+			PodNamespace:  "default",
+			Reason:        "FailedCreatePodSandBox",
+			Message:       "container veth name provided (eth0) already exists",
+			LastTimestamp: testNow,
+		}},
+		Checks:   []k8s.CheckResult{{Name: k8s.CheckHasOwner, Outcome: k8s.CheckPassed}},
+		Decision: DecisionRemediate,
+		Action:   k8s.ActionDelete,
+		Plan:     &k8s.Plan{Action: k8s.ActionDelete, Target: "Pod/" + pod, Description: "delete Pod default/" + pod, Time: testNow},
+		Outcome:  "deleted",
+		Response: "OK",
+	}
+}
+
+// readLines returns the lines of the audit log file at path
+func readLines(t *testing.T, path string) []string {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func TestWriteAndVerify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := Open(path, 0, 0, testKey)
+	require.NoError(t, err)
+	for _, pod := range []string{"foo", "bar", "baz"} {
+		require.NoError(t, l.Write(testRecord(pod)))
+	}
+	require.NoError(t, l.Close())
+
+	lines := readLines(t, path)
+	require.Len(t, lines, 3)
+
+	var entry Entry
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &entry))
+	var rec map[string]any
+	require.NoError(t, json.Unmarshal(entry.Record, &rec))
+	assert.EqualValues(t, 2, rec["seq"])
+	assert.Equal(t, "bar", rec["pod"])
+	assert.Equal(t, "remediate", rec["decision"])
+	assert.Equal(t, "OK", rec["response"])
+	assert.Equal(t, false, rec["dryRun"])
+	assert.Len(t, rec["events"], 1)
+	assert.Equal(t, []any{map[string]any{"name": "has-owner", "outcome": "passed"}}, rec["checks"])
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	hash, err := Verify(f, "", testKey)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal([]byte(lines[2]), &entry))
+	assert.Equal(t, entry.Hash, hash)
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := Open(path, 0, 0, testKey)
+	require.NoError(t, err)
+	for _, pod := range []string{"foo", "bar", "baz"} {
+		require.NoError(t, l.Write(testRecord(pod)))
+	}
+	require.NoError(t, l.Close())
+	lines := readLines(t, path)
+
+	testCases := []struct {
+		testName string
+		lines    []string
+	}{
+		{
+			testName: "Edited record",
+			lines:    []string{lines[0], strings.Replace(lines[1], `"decision":"remediate"`, `"decision":"skip"`, 1), lines[2]},
+		},
+		{
+			testName: "Removed line",
+			lines:    []string{lines[0], lines[2]},
+		},
+		{
+			testName: "Reordered lines",
+			lines:    []string{lines[1], lines[0], lines[2]},
+		},
+		{
+			testName: "Recomputed hash",
+			lines: []string{lines[0], func() string {
+				var entry Entry
+				require.NoError(t, json.Unmarshal([]byte(lines[1]), &entry))
+				entry.Record = bytes.Replace(entry.Record, []byte(`"bar"`), []byte(`"qux"`), 1)
+				entry.Hash = chainHash(nil, entry.PrevHash, entry.Record)
+				data, err := json.Marshal(entry)
+				require.NoError(t, err)
+				return string(data)
+			}(), lines[2]},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			_, err := Verify(strings.NewReader(strings.Join(test.lines, "\n")), "", testKey)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestOpenResumesChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := Open(path, 0, 0, testKey)
+	require.NoError(t, err)
+	require.NoError(t, l.Write(testRecord("foo")))
+	require.NoError(t, l.Close())
+
+	// a restarted process continues the sequence and the hash chain
+	l, err = Open(path, 0, 0, testKey)
+	require.NoError(t, err)
+	require.NoError(t, l.Write(testRecord("bar")))
+	require.NoError(t, l.Close())
+
+	lines := readLines(t, path)
+	require.Len(t, lines, 2)
+	var entry Entry
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &entry))
+	assert.Contains(t, string(entry.Record), `"seq":2`)
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = Verify(f, "", testKey)
+	assert.NoError(t, err)
+}
+
+func TestRotate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.log")
+	line, err := json.Marshal(testRecord("foo"))
+	require.NoError(t, err)
+
+	// every file holds two records
+	l, err := Open(path, int64(3*len(line)), 2, testKey)
+	require.NoError(t, err)
+	for i := 0; i < 7; i++ {
+		require.NoError(t, l.Write(testRecord(fmt.Sprintf("pod-%d", i))))
+	}
+	require.NoError(t, l.Close())
+
+	// the oldest file was removed
+	_, err = os.Stat(path + ".3")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.Len(t, readLines(t, path+".2"), 2)
+	assert.Len(t, readLines(t, path+".1"), 2)
+	assert.Len(t, readLines(t, path), 1)
+
+	// the chain continues across the rotated files, from the first line left by rotation
+	f, err := os.Open(path + ".2")
+	require.NoError(t, err)
+	hash, err := Anchor(f)
+	f.Close()
+	require.NoError(t, err)
+	assert.NotEmpty(t, hash)
+	for _, file := range []string{path + ".2", path + ".1", path} {
+		f, err := os.Open(file)
+		require.NoError(t, err)
+		hash, err = Verify(f, hash, testKey)
+		f.Close()
+		require.NoError(t, err, file)
+	}
+}
+
+func TestAnchor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := Open(path, 0, 0, testKey)
+	require.NoError(t, err)
+	for _, pod := range []string{"foo", "bar"} {
+		require.NoError(t, l.Write(testRecord(pod)))
+	}
+	require.NoError(t, l.Close())
+	lines := readLines(t, path)
+	var first Entry
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+
+	// the first record anchors the chain at "", so forging its previous hash is detected
+	forged := strings.Replace(lines[0], `"prevHash":""`, `"prevHash":"`+first.Hash+`"`, 1)
+
+	testCases := []struct {
+		testName     string
+		content      string
+		expectedHash string
+	}{
+		{testName: "First record", content: strings.Join(lines, "\n"), expectedHash: ""},
+		{testName: "Older files removed", content: lines[1], expectedHash: first.Hash},
+		{testName: "Forged first record", content: forged, expectedHash: ""},
+		{testName: "Empty file", content: "", expectedHash: ""},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			hash, err := Anchor(strings.NewReader(test.content))
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedHash, hash)
+		})
+	}
+	_, err = Verify(strings.NewReader(forged), "", testKey)
+	assert.Error(t, err)
+}
+
+func TestVerifyKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := Open(path, 0, 0, testKey)
+	require.NoError(t, err)
+	require.NoError(t, l.Write(testRecord("foo")))
+	require.NoError(t, l.Close())
+	content := strings.Join(readLines(t, path), "\n")
+
+	_, err = Verify(strings.NewReader(content), "", testKey)
+	assert.NoError(t, err)
+	_, err = Verify(strings.NewReader(content), "", []byte("other"))
+	assert.Error(t, err)
+	_, err = Verify(strings.NewReader(content), "", nil)
+	assert.Error(t, err)
+}
+
+func TestNilLogIgnoresRecords(t *testing.T) {
+	var l *Log
+	assert.NoError(t, l.Write(testRecord("foo")))
+	assert.NoError(t, l.Close())
+}
+
+func TestOpenRejectsNegativeLimits(t *testing.T) {
+	_, err := Open(filepath.Join(t.TempDir(), "audit.log"), -1, 0, nil)
+	assert.Error(t, err)
+}
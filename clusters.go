@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"github.com/andreistefanciprian/pod-restarter-go/config"
+	k8s "github.com/andreistefanciprian/pod-restarter-go/kubernetes"
+	"github.com/andreistefanciprian/pod-restarter-go/logging"
+	"github.com/andreistefanciprian/pod-restarter-go/reconciler"
+	"k8s.io/utils/clock"
+)
+
+// clusters returns the clusters set with --contexts and --kubeconfigs
+// Contexts are named after themselves, kubeconfig files after their base name without extension
+func clusters() []config.Cluster {
+	var list []config.Cluster
+	for _, context := range contexts {
+		list = append(list, config.Cluster{Name: context, Context: context})
+	}
+	for _, file := range kubeconfigs {
+		name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		list = append(list, config.Cluster{Name: name, Kubeconfig: file})
+	}
+	return list
+}
+
+// newReconcilers returns a Reconciler for the cluster of the kubeconfig (or the one pod-restarter runs in),
+// or one per cluster in multi-cluster mode
+// In multi-cluster mode a cluster whose client cannot be built is logged and left out, so it does not stop the others,
+// and the pause ConfigMap is read in the cluster of the kubeconfig (or the one pod-restarter runs in) for every cluster
+func newReconcilers(cfg *config.Config, clk clock.Clock, clientOpts k8s.ClientOptions, opts reconciler.Options) ([]*reconciler.Reconciler, error) {
+	if len(cfg.Clusters) == 0 {
+		c, err := k8s.NewK8sClient(clientOpts)
+		if err != nil {
+			return nil, fmt.Errorf("Could not create kubernetes client: %w", err)
+		}
+		r, err := reconciler.New(c, cfg, clk, opts)
+		if err != nil {
+			return nil, err
+		}
+		return []*reconciler.Reconciler{r}, nil
+	}
+
+	if opts.PauseConfigMap != "" {
+		home, err := k8s.NewK8sClient(clientOpts)
+		if err != nil {
+			return nil, fmt.Errorf("Could not create the kubernetes client of the pause ConfigMap: %w", err)
+		}
+		opts.PauseClient = home
+	}
+
+	var rs []*reconciler.Reconciler
+	for i, cluster := range cfg.Clusters {
+		clusterCfg, err := cfg.ClusterConfig(i)
+		if err != nil {
+			return nil, err
+		}
+		co := clientOpts
+		co.Cluster = cluster.Name
+		co.Context = cluster.Context
+		if cluster.Kubeconfig != "" {
+			co.Kubeconfig = cluster.Kubeconfig
+		}
+		if cluster.QPS > 0 {
+			co.QPS = cluster.QPS
+		}
+		if cluster.Burst > 0 {
+			co.Burst = cluster.Burst
+		}
+		c, err := k8s.NewK8sClient(co)
+		if err != nil {
+			slog.Error("Could not create kubernetes client", logging.KeyCluster, cluster.Name, logging.Err(err))
+			continue
+		}
+
+		o := opts
+		o.Cluster = cluster.Name
+		r, err := reconciler.New(c, clusterCfg, clk, o)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s: %w", cluster.Name, err)
+		}
+		rs = append(rs, r)
+	}
+	if len(rs) == 0 {
+		return nil, errors.New("Could not create a kubernetes client for any cluster")
+	}
+	return rs, nil
+}
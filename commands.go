@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andreistefanciprian/pod-restarter-go/audit"
+	"github.com/andreistefanciprian/pod-restarter-go/config"
+	"github.com/andreistefanciprian/pod-restarter-go/health"
+	k8s "github.com/andreistefanciprian/pod-restarter-go/kubernetes"
+	"github.com/andreistefanciprian/pod-restarter-go/logging"
+	"github.com/andreistefanciprian/pod-restarter-go/reconciler"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// subcommands
+const (
+	commandRun       = "run"
+	commandOnce      = "once"
+	commandScan      = "scan"
+	commandExplain   = "explain"
+	commandSimulate  = "simulate"
+	commandVerify    = "verify-audit"
+	commandPreflight = "preflight"
+)
+
+// --preflight modes
+const (
+	preflightWarn = "warn"
+	preflightFail = "fail"
+	preflightOff  = "off"
+)
+
+// exit codes
+const (
+	exitOK      = 0 // every candidate Pod was remediated or skipped
+	exitError   = 1 // invalid configuration, or Pods could not be listed
+	exitUsage   = 2 // unknown subcommand or invalid arguments
+	exitPartial = 3 // some candidate Pods could not be checked or remediated
+)
+
+// preflight reviews the permissions of every cluster
+// The returned error joins the errors of the clusters whose permissions could not be reviewed
+func preflight(ctx context.Context, rs []*reconciler.Reconciler) (*reconciler.Preflight, error) {
+	reviews := make([][]k8s.AccessReview, len(rs))
+	errs := make([]error, len(rs))
+	forEach(rs, func(i int, r *reconciler.Reconciler) {
+		reviews[i], errs[i] = r.ReviewAccess(ctx)
+		if errs[i] != nil && r.Cluster() != "" {
+			errs[i] = fmt.Errorf("cluster %s: %w", r.Cluster(), errs[i])
+		}
+	})
+	doc := &reconciler.Preflight{}
+	for i, r := range rs {
+		doc.Add(r.Cluster(), reviews[i])
+	}
+	// in multi-cluster mode every cluster shares the pause ConfigMap of the cluster pod-restarter runs in, reviewed once
+	if len(rs) > 0 {
+		pauseReviews, err := rs[0].ReviewPauseAccess(ctx)
+		doc.Add("", pauseReviews)
+		errs = append(errs, err)
+	}
+	return doc, errors.Join(errs...)
+}
+
+// preflightCommand writes the permissions every cluster lacks, and fails if any is missing or could not be reviewed
+func preflightCommand(ctx context.Context, rs []*reconciler.Reconciler, w io.Writer, format string) int {
+	doc, err := preflight(ctx, rs)
+	if err != nil {
+		slog.Error("Could not review permissions", logging.Err(err))
+	}
+	werr := reconciler.WritePreflight(w, format, doc)
+	if werr != nil {
+		slog.Error("Could not write preflight", logging.Err(werr))
+		return exitError
+	}
+	if err != nil || len(doc.Missing) > 0 {
+		return exitError
+	}
+	return exitOK
+}
+
+// startupPreflight reviews the permissions of every cluster before run and once start, as set by --preflight:
+// missing permissions are logged, and stop pod-restarter in fail mode
+func startupPreflight(ctx context.Context, rs []*reconciler.Reconciler, mode string) bool {
+	if mode == preflightOff {
+		return true
+	}
+	doc, err := preflight(ctx, rs)
+	if err != nil {
+		slog.Warn("Could not review permissions", logging.Err(err))
+	}
+	for _, m := range doc.Missing {
+		log := slog.Default()
+		if m.Cluster != "" {
+			log = log.With(logging.KeyCluster, m.Cluster)
+		}
+		log.Warn("Missing permission", "permission", m.Permission.String(), logging.KeyNamespace, m.Namespace, "name", m.Name, "reason", m.Reason)
+	}
+	if mode == preflightFail && (err != nil || len(doc.Missing) > 0) {
+		slog.Error("Missing permissions, run the preflight command for details", "missing", len(doc.Missing))
+		return false
+	}
+	return true
+}
+
+// runCommand runs the run daemon of every cluster until ctx is cancelled
+// The health endpoints are served on addr when status is set
+func runCommand(ctx context.Context, rs []*reconciler.Reconciler, status *health.Status, addr string) int {
+	if status != nil {
+		err := health.Serve(ctx, addr, status.Handler())
+		if err != nil {
+			slog.Error("Could not serve health endpoints", logging.Err(err))
+			return exitError
+		}
+	}
+	forEach(rs, func(_ int, r *reconciler.Reconciler) {
+		r.Run(ctx)
+	})
+	slog.Info("Stopped")
+	return exitOK
+}
+
+// onceCommand runs a single scan and remediation cycle in every cluster
+func onceCommand(ctx context.Context, rs []*reconciler.Reconciler) int {
+	codes := make([]int, len(rs))
+	forEach(rs, func(i int, r *reconciler.Reconciler) {
+		s, err := r.RunOnce(ctx)
+		switch {
+		case err != nil:
+			codes[i] = exitError
+		case s.Failed > 0:
+			codes[i] = exitPartial
+		}
+	})
+	return worst(codes)
+}
+
+// scanCommand writes a report of the candidate Pods of every rule in every cluster without remediating them
+func scanCommand(ctx context.Context, rs []*reconciler.Reconciler, w io.Writer, format string) int {
+	docs := make([]*reconciler.Report, len(rs))
+	codes := make([]int, len(rs))
+	forEach(rs, func(i int, r *reconciler.Reconciler) {
+		candidates, err := r.Scan(ctx)
+		docs[i] = r.Report(ctx, candidates)
+		if err != nil {
+			codes[i] = exitError
+		}
+	})
+
+	doc := docs[0]
+	for _, d := range docs[1:] {
+		doc.Candidates = append(doc.Candidates, d.Candidates...)
+	}
+	werr := reconciler.WriteReport(w, format, doc)
+	if werr != nil {
+		slog.Error("Could not write report", logging.Err(werr))
+		return exitError
+	}
+
+	for _, pr := range doc.Candidates {
+		if pr.Failed() {
+			codes = append(codes, exitPartial)
+		}
+	}
+	return worst(codes)
+}
+
+// forEach calls fn with every Reconciler in its own goroutine and waits for all of them,
+// so a slow or unreachable cluster does not hold up the others
+func forEach(rs []*reconciler.Reconciler, fn func(i int, r *reconciler.Reconciler)) {
+	var wg sync.WaitGroup
+	for i, r := range rs {
+		wg.Add(1)
+		go func(i int, r *reconciler.Reconciler) {
+			defer wg.Done()
+			fn(i, r)
+		}(i, r)
+	}
+	wg.Wait()
+}
+
+// worst returns the most severe exit code: exitError, then exitPartial, then exitOK
+func worst(codes []int) int {
+	code := exitOK
+	for _, c := range codes {
+		if c == exitError {
+			return exitError
+		}
+		if c == exitPartial {
+			code = exitPartial
+		}
+	}
+	return code
+}
+
+// explainCommand prints why the Pod target (namespace/pod) would or would not be restarted by every rule of every cluster
+func explainCommand(ctx context.Context, rs []*reconciler.Reconciler, w io.Writer, target string) int {
+	namespace, pod, ok := strings.Cut(target, "/")
+	if !ok || namespace == "" || pod == "" {
+		slog.Error("Invalid Pod, expected <namespace>/<pod>", "target", target)
+		return exitUsage
+	}
+	code := exitOK
+	for i, r := range rs {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		err := r.Explain(ctx, w, namespace, pod)
+		if err != nil {
+			slog.Error("Could not explain Pod", logging.KeyCluster, r.Cluster(), logging.KeyNamespace, namespace, logging.KeyPod, pod, logging.Err(err))
+			code = exitError
+		}
+	}
+	return code
+}
+
+// simulateCommand replays the objects recorded in files and writes what would have been restarted
+func simulateCommand(ctx context.Context, cfg *config.Config, w io.Writer, format string, files []string) int {
+	var objects []runtime.Object
+	for _, file := range files {
+		loaded, err := loadFile(file)
+		if err != nil {
+			slog.Error("Could not load recorded objects", "file", file, logging.Err(err))
+			return exitError
+		}
+		objects = append(objects, loaded...)
+	}
+
+	restarts, err := reconciler.Simulate(ctx, cfg, objects, time.Duration(pollingInterval)*time.Second, pageSize)
+	if err != nil {
+		slog.Error("Could not simulate", logging.Err(err))
+		return exitError
+	}
+	err = reconciler.WriteSimulation(w, format, restarts)
+	if err != nil {
+		slog.Error("Could not write report", logging.Err(err))
+		return exitError
+	}
+	return exitOK
+}
+
+// verifyAuditCommand verifies the hash chain of audit log files keyed with key, given from the oldest to the most recent
+// The chain starts at the first line of the oldest file, since older files may have been removed by rotation
+func verifyAuditCommand(w io.Writer, files []string, key []byte) int {
+	var hash string
+	for i, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			slog.Error("Could not open audit log", "file", file, logging.Err(err))
+			return exitError
+		}
+		if i == 0 {
+			hash, err = audit.Anchor(f)
+			if err == nil {
+				_, err = f.Seek(0, io.SeekStart)
+			}
+			if err != nil {
+				f.Close()
+				slog.Error("Could not read audit log", "file", file, logging.Err(err))
+				return exitError
+			}
+		}
+		hash, err = audit.Verify(f, hash, key)
+		f.Close()
+		if err != nil {
+			slog.Error("Audit log has been tampered with", "file", file, logging.Err(err))
+			return exitError
+		}
+	}
+	fmt.Fprintf(w, "OK: the hash chain of %d file(s) is intact, last hash %s\n", len(files), hash)
+	return exitOK
+}
+
+// loadFile returns the objects recorded in a JSON or YAML file
+func loadFile(file string) ([]runtime.Object, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return k8s.LoadObjects(f)
+}
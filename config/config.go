@@ -0,0 +1,234 @@
+// Package config loads pod-restarter rules from a YAML/JSON config file
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	k8s "github.com/andreistefanciprian/pod-restarter-go/kubernetes"
+	"github.com/andreistefanciprian/pod-restarter-go/notify"
+	"github.com/andreistefanciprian/pod-restarter-go/schedule"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/yaml"
+)
+
+// Config holds the settings loaded from the config file
+// The embedded PodFilter selects the namespaces and Pods all rules apply to
+// Checks is the ordered pre-deletion check pipeline used by rules that do not set their own
+// Notifiers are the webhooks told about the remediation outcomes of every cycle
+// Schedule sets the maintenance and blackout windows of all rules
+// MinAvailable is the minimum of ready replicas (eg: 1 or "50%") the owners of remediated Pods keep, for rules that do not set their own
+// Clusters, when set, are reconciled by independent loops instead of the cluster pod-restarter runs in
+type Config struct {
+	k8s.PodFilter `json:",inline"`
+	Checks        []string            `json:"checks,omitempty"`
+	Rules         []Rule              `json:"rules"`
+	Notifiers     []notify.Webhook    `json:"notifiers,omitempty"`
+	Schedule      *schedule.Schedule  `json:"schedule,omitempty"`
+	MinAvailable  *intstr.IntOrString `json:"minAvailable,omitempty"`
+	Clusters      []Cluster           `json:"clusters,omitempty"`
+}
+
+// Cluster is a cluster reconciled by its own loop in multi-cluster mode
+// It is reached through Kubeconfig (--kubeconfig when empty) and Context (the current context when empty), and Name defaults to Context
+// Rules and MinAvailable replace the global ones, QPS and Burst the --kube-api-qps and --kube-api-burst limits
+type Cluster struct {
+	Name         string              `json:"name"`
+	Kubeconfig   string              `json:"kubeconfig,omitempty"`
+	Context      string              `json:"context,omitempty"`
+	QPS          float32             `json:"qps,omitempty"`
+	Burst        int                 `json:"burst,omitempty"`
+	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
+	Rules        []Rule              `json:"rules,omitempty"`
+}
+
+// Rule targets failing Pods that have Events matching Reason and Message
+// Checks is the ordered list of registered checks a Pod must pass before it is remediated
+// The embedded ActionSpec selects the registered Action (delete by default) and how matching Pods are remediated
+// Schedule further restricts when the rule remediates Pods, on top of the global schedule
+// MinAvailable adds the min-available check to the end of the pipeline
+// Type specializes the rule: image-pull rules default to image pull back-off Events and add the image-pulled check
+type Rule struct {
+	Name           string              `json:"name"`
+	Type           string              `json:"type,omitempty"`
+	Reason         string              `json:"reason"`
+	Message        string              `json:"message"`
+	Checks         []string            `json:"checks,omitempty"`
+	Schedule       *schedule.Schedule  `json:"schedule,omitempty"`
+	MinAvailable   *intstr.IntOrString `json:"minAvailable,omitempty"`
+	k8s.ActionSpec `json:",inline"`
+}
+
+// RuleTypeImagePull is the type of the rules that only restart Pods failing to pull an image that was pulled on some node
+const RuleTypeImagePull = "image-pull"
+
+// Load reads and validates the config file at path
+func Load(path string) (*Config, error) {
+	cfg, err := Read(path)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Read parses the config file at path without validating it
+// Command line flags override its global settings before Validate copies them into the rules
+func Read(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read config file %s: %w", path, err)
+	}
+
+	var cfg Config
+	err = yaml.UnmarshalStrict(data, &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse config file %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// Validate sets rule defaults and returns error if a rule is incomplete or invalid
+// In multi-cluster mode the config of every cluster is validated instead
+// Checks and actions must be registered before Validate is called
+func (c *Config) Validate() error {
+	if len(c.Clusters) > 0 {
+		return c.validateClusters()
+	}
+	if len(c.Rules) == 0 {
+		return errors.New("config must define at least one rule")
+	}
+
+	err := c.PodFilter.Validate()
+	if err != nil {
+		return err
+	}
+
+	if c.Schedule != nil {
+		err = c.Schedule.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.Checks == nil {
+		c.Checks = k8s.DefaultChecks
+	}
+	_, err = k8s.LookupChecks(c.Checks)
+	if err != nil {
+		return err
+	}
+
+	names := make(map[string]bool)
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+		switch rule.Type {
+		case "":
+		case RuleTypeImagePull:
+			if rule.Reason == "" {
+				rule.Reason = k8s.ReasonBackOff
+				rule.Message = "Back-off pulling image"
+			}
+		default:
+			return fmt.Errorf("rule %d: unknown type %q: expected %q", i, rule.Type, RuleTypeImagePull)
+		}
+		if rule.Reason == "" {
+			return fmt.Errorf("rule %d: reason is required", i)
+		}
+		if rule.Name == "" {
+			rule.Name = rule.Reason
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("rule %s: name must be unique", rule.Name)
+		}
+		names[rule.Name] = true
+
+		_, err := k8s.NewAction(rule.ActionSpec)
+		if err != nil {
+			return fmt.Errorf("rule %s: %w", rule.Name, err)
+		}
+
+		if rule.Checks == nil {
+			rule.Checks = c.Checks
+		}
+		_, err = k8s.LookupChecks(rule.Checks)
+		if err != nil {
+			return fmt.Errorf("rule %s: %w", rule.Name, err)
+		}
+
+		if rule.Schedule != nil {
+			err = rule.Schedule.Validate()
+			if err != nil {
+				return fmt.Errorf("rule %s: %w", rule.Name, err)
+			}
+		}
+
+		if rule.MinAvailable == nil {
+			rule.MinAvailable = c.MinAvailable
+		}
+		if rule.MinAvailable != nil {
+			err = k8s.ValidateMinAvailable(*rule.MinAvailable)
+			if err != nil {
+				return fmt.Errorf("rule %s: %w", rule.Name, err)
+			}
+		}
+	}
+
+	for i := range c.Notifiers {
+		err := c.Notifiers[i].Validate()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateClusters returns error if a cluster is unnamed, named twice or has an invalid config
+func (c *Config) validateClusters() error {
+	names := make(map[string]bool)
+	for i := range c.Clusters {
+		cluster := &c.Clusters[i]
+		if cluster.Name == "" {
+			cluster.Name = cluster.Context
+		}
+		if cluster.Name == "" {
+			return fmt.Errorf("cluster %d: name or context is required", i)
+		}
+		if names[cluster.Name] {
+			return fmt.Errorf("cluster %s: name must be unique", cluster.Name)
+		}
+		names[cluster.Name] = true
+
+		_, err := c.ClusterConfig(i)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClusterConfig returns the validated config of the cluster at index i: the global settings with the rules
+// and minimum available of the cluster
+// Rules are copied, so the defaults set for a cluster never leak into the others
+func (c *Config) ClusterConfig(i int) (*Config, error) {
+	cluster := c.Clusters[i]
+	cfg := *c
+	cfg.Clusters = nil
+	if cluster.Rules != nil {
+		cfg.Rules = cluster.Rules
+	}
+	cfg.Rules = append([]Rule(nil), cfg.Rules...)
+	if cluster.MinAvailable != nil {
+		cfg.MinAvailable = cluster.MinAvailable
+	}
+	err := cfg.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("cluster %s: %w", cluster.Name, err)
+	}
+	return &cfg, nil
+}
@@ -0,0 +1,385 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	k8s "github.com/andreistefanciprian/pod-restarter-go/kubernetes"
+	"github.com/andreistefanciprian/pod-restarter-go/notify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte(content), 0600)
+	require.NoError(t, err)
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	tests := map[string]struct {
+		content     string
+		expectError bool
+		validate    func(t *testing.T, cfg *Config)
+	}{
+		"Load rules with DeletePolicy": {
+			content: `
+rules:
+  - name: veth
+    reason: FailedCreatePodSandBox
+    message: container veth name provided (eth0) already exists
+    gracePeriodSeconds: 0
+    propagationPolicy: Background
+  - reason: BackOff
+    message: Back-off pulling image
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				require.Len(t, cfg.Rules, 2)
+
+				veth := cfg.Rules[0]
+				assert.Equal(t, "veth", veth.Name)
+				require.NotNil(t, veth.GracePeriodSeconds)
+				assert.Equal(t, int64(0), *veth.GracePeriodSeconds)
+				require.NotNil(t, veth.PropagationPolicy)
+				assert.Equal(t, metav1.DeletePropagationBackground, *veth.PropagationPolicy)
+
+				// rule name defaults to reason and DeletePolicy respects Pod spec
+				backoff := cfg.Rules[1]
+				assert.Equal(t, "BackOff", backoff.Name)
+				assert.Nil(t, backoff.GracePeriodSeconds)
+				assert.Nil(t, backoff.PropagationPolicy)
+			},
+		},
+		"Load namespace and Pod filters": {
+			content: `
+namespaces:
+  exclude: [kube-system, istio-system, monitoring]
+  selector: team=platform
+podSelector: app=nginx
+rules:
+  - reason: BackOff
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, []string{"kube-system", "istio-system", "monitoring"}, cfg.Namespaces.Exclude)
+				assert.Equal(t, "team=platform", cfg.Namespaces.Selector)
+				assert.Equal(t, "app=nginx", cfg.PodSelector)
+			},
+		},
+		"Reject invalid namespace pattern": {
+			content: `
+namespaces:
+  include: ["team-["]
+rules:
+  - reason: BackOff
+`,
+			expectError: true,
+		},
+		"Load check pipelines": {
+			content: `
+checks: [has-owner, unhealthy]
+rules:
+  - reason: BackOff
+  - reason: FailedCreatePodSandBox
+    checks: [not-terminating]
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, []string{"has-owner", "unhealthy"}, cfg.Rules[0].Checks)
+				assert.Equal(t, []string{"not-terminating"}, cfg.Rules[1].Checks)
+			},
+		},
+		"Default check pipeline": {
+			content: `
+rules:
+  - reason: BackOff
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, k8s.DefaultChecks, cfg.Checks)
+				assert.Equal(t, k8s.DefaultChecks, cfg.Rules[0].Checks)
+			},
+		},
+		"Rule actions": {
+			content: `
+rules:
+  - name: evict
+    reason: BackOff
+    action: evict
+  - name: mark
+    reason: FailedMount
+    action: label
+    labels:
+      pod-restarter/failing: "true"
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, k8s.ActionEvict, cfg.Rules[0].Action)
+				assert.Equal(t, k8s.ActionLabel, cfg.Rules[1].Action)
+				assert.Equal(t, map[string]string{"pod-restarter/failing": "true"}, cfg.Rules[1].Labels)
+			},
+		},
+		"Notifiers": {
+			content: `
+rules:
+  - reason: BackOff
+notifiers:
+  - url: https://hooks.slack.com/services/T0/B0/X
+    format: slack
+  - url: https://alerts.example.com/pod-restarter
+    secret: s3cr3t
+    retries: 5
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				require.Len(t, cfg.Notifiers, 2)
+				assert.Equal(t, notify.FormatSlack, cfg.Notifiers[0].Format)
+				assert.Equal(t, notify.FormatGeneric, cfg.Notifiers[1].Format)
+				assert.Equal(t, "s3cr3t", cfg.Notifiers[1].Secret)
+				assert.Equal(t, 5, *cfg.Notifiers[1].Retries)
+			},
+		},
+		"Reject invalid notifier": {
+			content: `
+rules:
+  - reason: BackOff
+notifiers:
+  - url: https://alerts.example.com
+    format: teams
+`,
+			expectError: true,
+		},
+		"Schedules": {
+			content: `
+schedule:
+  timezone: Europe/London
+  deny:
+    - cron: "0 18 * * FRI"
+      duration: 63h
+rules:
+  - reason: BackOff
+    schedule:
+      allow:
+        - cron: "0 2 * * *"
+          duration: 2h
+  - reason: FailedCreatePodSandBox
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				require.NotNil(t, cfg.Schedule)
+				assert.Equal(t, "Europe/London", cfg.Schedule.Timezone)
+				assert.Equal(t, 63*time.Hour, cfg.Schedule.Deny[0].Duration.Duration)
+				require.NotNil(t, cfg.Rules[0].Schedule)
+				assert.Len(t, cfg.Rules[0].Schedule.Allow, 1)
+				assert.Nil(t, cfg.Rules[1].Schedule)
+			},
+		},
+		"Min available": {
+			content: `
+minAvailable: 50%
+rules:
+  - reason: BackOff
+  - reason: FailedCreatePodSandBox
+    minAvailable: 2
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, "50%", cfg.Rules[0].MinAvailable.String())
+				assert.Equal(t, "2", cfg.Rules[1].MinAvailable.String())
+			},
+		},
+		"Reject invalid min available": {
+			content: `
+rules:
+  - reason: BackOff
+    minAvailable: half
+`,
+			expectError: true,
+		},
+		"Clusters": {
+			content: `
+minAvailable: 1
+rules:
+  - reason: BackOff
+clusters:
+  - context: prod-eu
+    qps: 20
+    burst: 40
+    minAvailable: 50%
+  - name: dev
+    kubeconfig: /etc/pod-restarter/dev.kubeconfig
+    rules:
+      - reason: FailedCreatePodSandBox
+        action: evict
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				require.Len(t, cfg.Clusters, 2)
+				assert.Equal(t, "prod-eu", cfg.Clusters[0].Name)
+				assert.Equal(t, float32(20), cfg.Clusters[0].QPS)
+
+				prod, err := cfg.ClusterConfig(0)
+				require.NoError(t, err)
+				require.Len(t, prod.Rules, 1)
+				assert.Equal(t, "BackOff", prod.Rules[0].Name)
+				assert.Equal(t, "50%", prod.Rules[0].MinAvailable.String())
+				assert.Nil(t, prod.Clusters)
+
+				dev, err := cfg.ClusterConfig(1)
+				require.NoError(t, err)
+				require.Len(t, dev.Rules, 1)
+				assert.Equal(t, "FailedCreatePodSandBox", dev.Rules[0].Name)
+				assert.Equal(t, "1", dev.Rules[0].MinAvailable.String())
+
+				// the defaults of a cluster do not leak into the global rules
+				assert.Nil(t, cfg.Rules[0].MinAvailable)
+			},
+		},
+		"Reject unnamed cluster": {
+			content: `
+rules:
+  - reason: BackOff
+clusters:
+  - kubeconfig: /etc/pod-restarter/dev.kubeconfig
+`,
+			expectError: true,
+		},
+		"Reject duplicate cluster": {
+			content: `
+rules:
+  - reason: BackOff
+clusters:
+  - context: prod
+  - name: prod
+    context: prod-eu
+`,
+			expectError: true,
+		},
+		"Reject cluster without rules": {
+			content: `
+clusters:
+  - context: prod
+`,
+			expectError: true,
+		},
+		"Reject invalid schedule": {
+			content: `
+schedule:
+  deny:
+    - cron: "every friday"
+      duration: 1h
+rules:
+  - reason: BackOff
+`,
+			expectError: true,
+		},
+		"Reject invalid rule schedule timezone": {
+			content: `
+rules:
+  - reason: BackOff
+    schedule:
+      timezone: Mars/Olympus_Mons
+`,
+			expectError: true,
+		},
+		"Reject unknown action": {
+			content: `
+rules:
+  - reason: BackOff
+    action: reboot-node
+`,
+			expectError: true,
+		},
+		"Reject label action without labels": {
+			content: `
+rules:
+  - reason: BackOff
+    action: label
+`,
+			expectError: true,
+		},
+		"Reject unknown check": {
+			content: `
+rules:
+  - reason: BackOff
+    checks: [has-owner, does-not-exist]
+`,
+			expectError: true,
+		},
+		"Reject config without rules": {
+			content:     `rules: []`,
+			expectError: true,
+		},
+		"Reject rule without reason": {
+			content: `
+rules:
+  - name: missing-reason
+    message: Back-off pulling image
+`,
+			expectError: true,
+		},
+		"Reject duplicate rule names": {
+			content: `
+rules:
+  - reason: BackOff
+  - reason: BackOff
+`,
+			expectError: true,
+		},
+		"Reject negative grace period": {
+			content: `
+rules:
+  - reason: BackOff
+    gracePeriodSeconds: -1
+`,
+			expectError: true,
+		},
+		"Default image-pull rules to image pull back-off Events": {
+			content: `
+rules:
+  - type: image-pull
+  - name: registry
+    type: image-pull
+    reason: Failed
+    message: Failed to pull image
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				require.Len(t, cfg.Rules, 2)
+				assert.Equal(t, "BackOff", cfg.Rules[0].Name)
+				assert.Equal(t, "BackOff", cfg.Rules[0].Reason)
+				assert.Equal(t, "Back-off pulling image", cfg.Rules[0].Message)
+				assert.Equal(t, "Failed", cfg.Rules[1].Reason)
+				assert.Equal(t, "Failed to pull image", cfg.Rules[1].Message)
+			},
+		},
+		"Reject unknown rule type": {
+			content: `
+rules:
+  - type: crash-loop
+    reason: BackOff
+`,
+			expectError: true,
+		},
+		"Reject unknown fields": {
+			content: `
+rules:
+  - reason: BackOff
+    gracePeriod: 10
+`,
+			expectError: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := Load(writeConfig(t, tc.content))
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			tc.validate(t, cfg)
+		})
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	_, err := Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,204 @@
+// Package health serves the readiness and liveness of pod-restarter over HTTP
+package health
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/andreistefanciprian/pod-restarter-go/logging"
+	"k8s.io/utils/clock"
+)
+
+// Endpoints served by Handler
+const (
+	PathHealthz = "/healthz"
+	PathReadyz  = "/readyz"
+	PathLivez   = "/livez"
+	PathVars    = "/debug/vars"
+)
+
+// Status records the progress of the main loop of every cluster
+// It is ready once every cluster has listed Pods and Events successfully, and live while every cluster completes cycles within maxCycleAge
+// A nil Status ignores updates, so the main loop can run without health endpoints
+type Status struct {
+	clock       clock.PassiveClock
+	maxCycleAge time.Duration
+	started     time.Time
+
+	mu       sync.RWMutex
+	clusters map[string]*loop
+}
+
+// loop is the progress of the main loop of a cluster
+type loop struct {
+	ready     bool
+	lastCycle time.Time
+}
+
+// NewStatus returns a Status that is not ready, and that is live until maxCycleAge elapses without a completed cycle
+func NewStatus(clk clock.PassiveClock, maxCycleAge time.Duration) *Status {
+	return &Status{
+		clock:       clk,
+		maxCycleAge: maxCycleAge,
+		started:     clk.Now(),
+		clusters:    map[string]*loop{},
+	}
+}
+
+// Track adds the main loop of cluster ("" when pod-restarter reconciles a single cluster) to the checks
+// Every tracked cluster must list successfully before the Status is ready, and complete cycles for it to stay live
+func (s *Status) Track(cluster string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loop(cluster)
+}
+
+// loop returns the progress of cluster, tracking it if needed
+// The caller must hold the write lock
+func (s *Status) loop(cluster string) *loop {
+	l, ok := s.clusters[cluster]
+	if !ok {
+		l = &loop{lastCycle: s.clock.Now()}
+		s.clusters[cluster] = l
+	}
+	return l
+}
+
+// Listed marks cluster ready after its first successful listing
+func (s *Status) Listed(cluster string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loop(cluster).ready = true
+}
+
+// CycleCompleted records the time a cycle of the main loop of cluster completed
+func (s *Status) CycleCompleted(cluster string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loop(cluster).lastCycle = s.clock.Now()
+}
+
+// Ready returns an error until every cluster has listed successfully
+func (s *Status) Ready() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.clusters) == 0 {
+		return errors.New("Pods and Events have not been listed yet")
+	}
+	var errs []error
+	for _, cluster := range s.names() {
+		if !s.clusters[cluster].ready {
+			errs = append(errs, errors.New(prefix(cluster)+"Pods and Events have not been listed yet"))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Live returns an error if a cluster has not completed a cycle within maxCycleAge
+// The cluster with the oldest completed cycle is reported
+func (s *Status) Live() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	oldest, lastCycle := "", s.started
+	for i, cluster := range s.names() {
+		if l := s.clusters[cluster]; i == 0 || l.lastCycle.Before(lastCycle) {
+			oldest, lastCycle = cluster, l.lastCycle
+		}
+	}
+	age := s.clock.Since(lastCycle)
+	if age > s.maxCycleAge {
+		return fmt.Errorf("%sno cycle has completed for %s (max %s)", prefix(oldest), age.Round(time.Second), s.maxCycleAge)
+	}
+	return nil
+}
+
+// names returns the tracked clusters, sorted
+func (s *Status) names() []string {
+	names := make([]string, 0, len(s.clusters))
+	for cluster := range s.clusters {
+		names = append(names, cluster)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// prefix returns the prefix of the errors of cluster, none in single-cluster mode
+func prefix(cluster string) string {
+	if cluster == "" {
+		return ""
+	}
+	return fmt.Sprintf("cluster %s: ", cluster)
+}
+
+// Handler serves /readyz, /livez and /healthz, which checks both
+// Endpoints answer 200 "ok" when the checks pass and 503 with the reason otherwise
+// The published expvars (eg: client_throttling) are served as JSON on /debug/vars
+func (s *Status) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(PathVars, expvar.Handler())
+	mux.HandleFunc(PathReadyz, check(s.Ready))
+	mux.HandleFunc(PathLivez, check(s.Live))
+	mux.HandleFunc(PathHealthz, check(func() error {
+		return errors.Join(s.Live(), s.Ready())
+	}))
+	return mux
+}
+
+// check returns a handler reporting the result of checkFn
+func check(checkFn func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		err := checkFn()
+		if err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, err)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	}
+}
+
+// Serve listens on addr and serves handler until ctx is cancelled
+// The returned error is only set when addr cannot be listened on; the server is stopped in the background
+func Serve(ctx context.Context, addr string, handler http.Handler) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("Could not listen on %q: %w", addr, err)
+	}
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		err := server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Health server stopped", logging.Err(err))
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	slog.Info("Serving health endpoints", "address", listener.Addr().String())
+	return nil
+}
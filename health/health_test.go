@@ -0,0 +1,174 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+var testNow = time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+
+func TestHandler(t *testing.T) {
+	testCases := []struct {
+		testName       string
+		update         func(s *Status, clk *testingclock.FakeClock)
+		expectedStatus map[string]int
+	}{
+		{
+			testName: "Not ready before the first listing",
+			expectedStatus: map[string]int{
+				PathReadyz:  http.StatusServiceUnavailable,
+				PathLivez:   http.StatusOK,
+				PathHealthz: http.StatusServiceUnavailable,
+			},
+		},
+		{
+			testName: "Ready after the first listing",
+			update: func(s *Status, clk *testingclock.FakeClock) {
+				s.Listed("")
+			},
+			expectedStatus: map[string]int{
+				PathReadyz:  http.StatusOK,
+				PathLivez:   http.StatusOK,
+				PathHealthz: http.StatusOK,
+			},
+		},
+		{
+			testName: "Live while cycles complete",
+			update: func(s *Status, clk *testingclock.FakeClock) {
+				s.Listed("")
+				clk.Step(80 * time.Second)
+				s.CycleCompleted("")
+				clk.Step(80 * time.Second)
+			},
+			expectedStatus: map[string]int{
+				PathReadyz:  http.StatusOK,
+				PathLivez:   http.StatusOK,
+				PathHealthz: http.StatusOK,
+			},
+		},
+		{
+			testName: "Not live when no cycle completes",
+			update: func(s *Status, clk *testingclock.FakeClock) {
+				s.Listed("")
+				s.CycleCompleted("")
+				clk.Step(91 * time.Second)
+			},
+			expectedStatus: map[string]int{
+				PathReadyz:  http.StatusOK,
+				PathLivez:   http.StatusServiceUnavailable,
+				PathHealthz: http.StatusServiceUnavailable,
+			},
+		},
+		{
+			testName: "Not live when the first cycle never completes",
+			update: func(s *Status, clk *testingclock.FakeClock) {
+				clk.Step(91 * time.Second)
+			},
+			expectedStatus: map[string]int{
+				PathReadyz:  http.StatusServiceUnavailable,
+				PathLivez:   http.StatusServiceUnavailable,
+				PathHealthz: http.StatusServiceUnavailable,
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			clk := testingclock.NewFakeClock(testNow)
+			s := NewStatus(clk, 90*time.Second)
+			if test.update != nil {
+				test.update(s, clk)
+			}
+			for path, expected := range test.expectedStatus {
+				rec := httptest.NewRecorder()
+				s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+				assert.Equal(t, expected, rec.Code, path)
+				if expected == http.StatusOK {
+					assert.Equal(t, "ok\n", rec.Body.String(), path)
+				}
+			}
+		})
+	}
+}
+
+func TestStatusClusters(t *testing.T) {
+	clk := testingclock.NewFakeClock(testNow)
+	s := NewStatus(clk, 90*time.Second)
+	s.Track("blue")
+	s.Track("green")
+
+	// readiness waits for the first listing of every cluster
+	s.Listed("blue")
+	assert.EqualError(t, s.Ready(), "cluster green: Pods and Events have not been listed yet")
+	s.Listed("green")
+	assert.NoError(t, s.Ready())
+
+	// a hung cluster fails liveness while the others complete cycles
+	for i := 0; i < 3; i++ {
+		clk.Step(60 * time.Second)
+		s.CycleCompleted("blue")
+		if i == 0 {
+			s.CycleCompleted("green")
+		}
+	}
+	assert.EqualError(t, s.Live(), "cluster green: no cycle has completed for 2m0s (max 1m30s)")
+	s.CycleCompleted("green")
+	assert.NoError(t, s.Live())
+}
+
+func TestHandlerServesVars(t *testing.T) {
+	s := NewStatus(testingclock.NewFakeClock(testNow), time.Minute)
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, PathVars, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"memstats"`)
+}
+
+func TestNilStatusIgnoresUpdates(t *testing.T) {
+	var s *Status
+	assert.NotPanics(t, func() {
+		s.Listed("")
+		s.CycleCompleted("")
+	})
+}
+
+func TestServe(t *testing.T) {
+	clk := testingclock.NewFakeClock(testNow)
+	s := NewStatus(clk, time.Minute)
+	s.Listed("")
+
+	// pick a free port
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, Serve(ctx, addr, s.Handler()))
+
+	resp, err := http.Get(fmt.Sprintf("http://%s%s", addr, PathHealthz))
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "ok\n", string(body))
+
+	// the address is in use until the server is stopped
+	assert.Error(t, Serve(context.Background(), addr, s.Handler()))
+	cancel()
+	assert.Eventually(t, func() bool {
+		_, err := http.Get(fmt.Sprintf("http://%s%s", addr, PathHealthz))
+		return err != nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -70,3 +70,28 @@ Allow the release namespace to be overridden
     {{- .Release.Namespace -}}
   {{- end -}}
 {{- end -}}
+
+{{/*
+RBAC rules on the namespaced resources pod-restarter reads and remediates
+*/}}
+{{- define "pod_restarter.namespacedRules" -}}
+# get/list/delete checks and deletes Pods, patch labels and annotates them
+- apiGroups: [""]
+  resources: ["pods"]
+  verbs: ["get", "list", "delete", "patch"]
+- apiGroups: [""]
+  resources: ["pods/eviction"]
+  verbs: ["create"]
+- apiGroups: [""]
+  resources: ["events"]
+  verbs: ["get", "watch", "list"]
+- apiGroups: ["apps"]
+  resources: ["replicasets"]
+  verbs: ["get"]
+- apiGroups: ["apps"]
+  resources: ["deployments", "statefulsets", "daemonsets"]
+  verbs: ["get", "patch"]
+- apiGroups: ["batch"]
+  resources: ["jobs"]
+  verbs: ["get"]
+{{- end }}
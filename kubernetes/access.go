@@ -0,0 +1,158 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Permission is an API verb on a resource that pod-restarter needs, in Namespace ("" for all namespaces)
+type Permission struct {
+	Verb        string `json:"verb"`
+	Group       string `json:"group,omitempty"`
+	Resource    string `json:"resource"`
+	Subresource string `json:"subresource,omitempty"`
+	Namespace   string `json:"namespace,omitempty"`
+	Name        string `json:"name,omitempty"`
+}
+
+// String returns the permission as "verb resource" (eg: "delete pods", "patch deployments.apps")
+func (p Permission) String() string {
+	resource := p.Resource
+	if p.Group != "" {
+		resource += "." + p.Group
+	}
+	if p.Subresource != "" {
+		resource += "/" + p.Subresource
+	}
+	return p.Verb + " " + resource
+}
+
+// readOnly returns true if the permission only reads objects
+func (p Permission) readOnly() bool {
+	return p.Verb == "get" || p.Verb == "list" || p.Verb == "watch"
+}
+
+// Permissioner is implemented by the actions and checks that call the API server, so that their permissions can be reviewed
+// Permissions returns the permissions needed to act on the Pods of namespace ("" for all namespaces)
+type Permissioner interface {
+	Permissions(namespace string) []Permission
+}
+
+// WithPermissions returns check declaring the permissions it needs, which are reviewed in the namespaces it runs in
+func WithPermissions(check Check, permissions ...Permission) Check {
+	return &permissionedCheck{check: check, permissions: permissions}
+}
+
+type permissionedCheck struct {
+	check       Check
+	permissions []Permission
+}
+
+func (c *permissionedCheck) Name() string {
+	return c.check.Name()
+}
+
+func (c *permissionedCheck) Check(ctx context.Context, clientSet kubernetes.Interface, pod *PodDetails) error {
+	return c.check.Check(ctx, clientSet, pod)
+}
+
+func (c *permissionedCheck) Permissions(namespace string) []Permission {
+	return inNamespace(c.permissions, namespace)
+}
+
+// inNamespace returns a copy of permissions in namespace
+func inNamespace(permissions []Permission, namespace string) []Permission {
+	scoped := make([]Permission, len(permissions))
+	for i, p := range permissions {
+		p.Namespace = namespace
+		scoped[i] = p
+	}
+	return scoped
+}
+
+// RulePermissions returns the permissions needed by the checks and the action of a rule to remediate Pods in namespace
+// Dry runs only plan remediations, so they only need the permissions that read objects
+func RulePermissions(checks []Check, action Action, namespace string, dryRun bool) []Permission {
+	var permissions []Permission
+	for _, check := range checks {
+		if p, ok := check.(Permissioner); ok {
+			permissions = append(permissions, p.Permissions(namespace)...)
+		}
+	}
+	if p, ok := action.(Permissioner); ok {
+		for _, permission := range p.Permissions(namespace) {
+			if !dryRun || permission.readOnly() {
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+	return permissions
+}
+
+// AccessReview is the answer of the API server to whether pod-restarter has a Permission
+// Reason explains a denial, when the authorizer gives one
+type AccessReview struct {
+	Permission `json:",inline"`
+	Allowed    bool   `json:"allowed"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// ReviewAccess asks the API server, with one SelfSubjectAccessReview per permission, whether the client credentials have them
+func (c *kubeClient) ReviewAccess(ctx context.Context, permissions []Permission) ([]AccessReview, error) {
+	api := c.clientSet.AuthorizationV1().SelfSubjectAccessReviews()
+	reviews := make([]AccessReview, 0, len(permissions))
+	for _, p := range permissions {
+		review, err := api.Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace:   p.Namespace,
+					Verb:        p.Verb,
+					Group:       p.Group,
+					Resource:    p.Resource,
+					Subresource: p.Subresource,
+					Name:        p.Name,
+				},
+			},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return reviews, fmt.Errorf("Could not review access to %s: %w", p, err)
+		}
+		reviews = append(reviews, AccessReview{
+			Permission: p,
+			Allowed:    review.Status.Allowed,
+			Reason:     strings.TrimSpace(review.Status.Reason + " " + review.Status.EvaluationError),
+		})
+	}
+	return reviews, nil
+}
+
+// ScopeNamespaces returns the namespaces pod-restarter calls the API server in for filter:
+// every included namespace when it only includes namespace names (always the case of scoped filters), all namespaces otherwise
+func ScopeNamespaces(filter NamespaceFilter) []string {
+	if len(filter.Include) > 0 && !hasGlob(filter.Include) {
+		return filter.Include
+	}
+	return []string{metav1.NamespaceAll}
+}
+
+// ScopePermissions returns the permissions needed to find failing Pods in the namespaces and with the labels selected by filter, and check them
+// Pods are only listed to match the Pod label selector
+func ScopePermissions(filter PodFilter) []Permission {
+	var permissions []Permission
+	if filter.Namespaces.Selector != "" {
+		permissions = append(permissions, Permission{Verb: "list", Resource: "namespaces"})
+	}
+	for _, namespace := range ScopeNamespaces(filter.Namespaces) {
+		permissions = append(permissions, Permission{Verb: "list", Resource: "events", Namespace: namespace})
+		if filter.PodSelector != "" {
+			permissions = append(permissions, Permission{Verb: "list", Resource: "pods", Namespace: namespace})
+		}
+		permissions = append(permissions, Permission{Verb: "get", Resource: "pods", Namespace: namespace})
+	}
+	return permissions
+}
@@ -0,0 +1,137 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestReviewAccess(t *testing.T) {
+	clientSet := fake.NewSimpleClientset()
+	// the Role in team-a only lets pod-restarter read Pods
+	clientSet.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attrs := review.Spec.ResourceAttributes
+		review.Status.Allowed = attrs.Namespace == "team-a" && attrs.Resource == "pods" && attrs.Verb != "delete"
+		if !review.Status.Allowed {
+			review.Status.Reason = "no RBAC policy matched"
+		}
+		return true, review, nil
+	})
+	clt := NewK8sClientFromClientSet(clientSet, 0, testingclock.NewFakeClock(testNow))
+
+	reviews, err := clt.ReviewAccess(context.TODO(), []Permission{
+		{Verb: "get", Resource: "pods", Namespace: "team-a"},
+		{Verb: "delete", Resource: "pods", Namespace: "team-a"},
+		{Verb: "get", Resource: "pods", Namespace: "team-b"},
+	})
+	require.NoError(t, err)
+	require.Len(t, reviews, 3)
+	assert.True(t, reviews[0].Allowed)
+	assert.False(t, reviews[1].Allowed)
+	assert.Equal(t, "no RBAC policy matched", reviews[1].Reason)
+	assert.False(t, reviews[2].Allowed)
+
+	clientSet.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("connection refused")
+	})
+	_, err = clt.ReviewAccess(context.TODO(), []Permission{{Verb: "get", Resource: "pods"}})
+	assert.ErrorContains(t, err, "get pods")
+}
+
+func TestPermissionString(t *testing.T) {
+	assert.Equal(t, "delete pods", Permission{Verb: "delete", Resource: "pods"}.String())
+	assert.Equal(t, "create pods/eviction", Permission{Verb: "create", Resource: "pods", Subresource: "eviction"}.String())
+	assert.Equal(t, "patch deployments.apps", Permission{Verb: "patch", Group: "apps", Resource: "deployments"}.String())
+}
+
+func TestScopePermissions(t *testing.T) {
+	tests := map[string]struct {
+		filter              PodFilter
+		expectedPermissions []string
+	}{
+		"All namespaces": {
+			expectedPermissions: []string{"list events in ", "get pods in "},
+		},
+		"Globs require all namespaces": {
+			filter:              PodFilter{Namespaces: NamespaceFilter{Include: []string{"team-*"}}},
+			expectedPermissions: []string{"list events in ", "get pods in "},
+		},
+		"Scoped namespaces": {
+			filter:              PodFilter{Namespaces: NamespaceFilter{Include: []string{"team-a", "team-b"}, Scoped: true}},
+			expectedPermissions: []string{"list events in team-a", "get pods in team-a", "list events in team-b", "get pods in team-b"},
+		},
+		"Namespace selector": {
+			filter:              PodFilter{Namespaces: NamespaceFilter{Selector: "team=a"}},
+			expectedPermissions: []string{"list namespaces in ", "list events in ", "get pods in "},
+		},
+		"Pod selector": {
+			filter:              PodFilter{Namespaces: NamespaceFilter{Include: []string{"team-a"}}, PodSelector: "app=web"},
+			expectedPermissions: []string{"list events in team-a", "list pods in team-a", "get pods in team-a"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var permissions []string
+			for _, p := range ScopePermissions(tc.filter) {
+				permissions = append(permissions, p.String()+" in "+p.Namespace)
+			}
+			assert.Equal(t, tc.expectedPermissions, permissions)
+		})
+	}
+}
+
+func TestRulePermissions(t *testing.T) {
+	guard, err := NewMinAvailableCheck(intstr.FromInt(1), NewInFlight())
+	require.NoError(t, err)
+	checks, err := LookupChecks(DefaultChecks)
+	require.NoError(t, err)
+	checks = append(checks, guard)
+
+	tests := map[string]struct {
+		action   string
+		dryRun   bool
+		expected []string
+	}{
+		"Delete": {
+			action:   ActionDelete,
+			expected: []string{"get statefulsets.apps", "get replicasets.apps", "get deployments.apps", "delete pods"},
+		},
+		"Evict": {
+			action:   ActionEvict,
+			expected: []string{"get statefulsets.apps", "get replicasets.apps", "get deployments.apps", "create pods/eviction"},
+		},
+		"Dry run only reads": {
+			action:   ActionRolloutRestart,
+			dryRun:   true,
+			expected: []string{"get statefulsets.apps", "get replicasets.apps", "get deployments.apps", "get replicasets.apps", "get jobs.batch"},
+		},
+		"Exec webhook": {
+			action:   ActionExecWebhook,
+			expected: []string{"get statefulsets.apps", "get replicasets.apps", "get deployments.apps"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			action, err := NewAction(ActionSpec{Action: tc.action, WebhookURL: "https://example.com/hook"})
+			require.NoError(t, err)
+			var got []string
+			for _, p := range RulePermissions(checks, action, "team-a", tc.dryRun) {
+				assert.Equal(t, "team-a", p.Namespace)
+				got = append(got, p.String())
+			}
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
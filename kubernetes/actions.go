@@ -0,0 +1,453 @@
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Action remediates a Pod that passed PodChecks
+// Plan describes what would be done without changing anything, Execute carries out the Plan
+// and DryRun reports the Plan as if it had been executed
+type Action interface {
+	Name() string
+	Plan(ctx context.Context, clientSet kubernetes.Interface, pod *PodDetails) (*Plan, error)
+	Execute(ctx context.Context, clientSet kubernetes.Interface, plan *Plan) (*Result, error)
+	DryRun(ctx context.Context, clientSet kubernetes.Interface, plan *Plan) (*Result, error)
+}
+
+// ActionFactory builds an Action from the settings of a rule
+type ActionFactory func(spec ActionSpec) (Action, error)
+
+// Plan describes the change an Action makes to remediate a Pod
+// Target is the object that is changed (eg: Pod/foo or Deployment/bar)
+// Time is when the Plan was made, according to the client clock
+type Plan struct {
+	Action      string      `json:"action"`
+	Pod         *PodDetails `json:"-"`
+	Target      string      `json:"target"`
+	Description string      `json:"description"`
+	Time        time.Time   `json:"time"`
+}
+
+// Result holds the outcome of executing or dry-running a Plan
+type Result struct {
+	Plan    *Plan  `json:"plan"`
+	DryRun  bool   `json:"dryRun"`
+	Outcome string `json:"outcome"`
+}
+
+// names of the built-in actions
+const (
+	ActionDelete         = "delete"
+	ActionEvict          = "evict"
+	ActionRolloutRestart = "rollout-restart"
+	ActionLabel          = "label"
+	ActionAnnotate       = "annotate"
+	ActionExecWebhook    = "exec-webhook"
+)
+
+// OutcomePlanned is the outcome of every dry-run Result
+const OutcomePlanned = "planned"
+
+// restartedAtAnnotation is the Pod template annotation kubectl sets on rollout restart
+const restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+
+var (
+	actionsMu sync.RWMutex
+	actions   = map[string]ActionFactory{}
+)
+
+func init() {
+	MustRegisterAction(ActionDelete, newDeleteAction)
+	MustRegisterAction(ActionEvict, newEvictAction)
+	MustRegisterAction(ActionRolloutRestart, newRolloutRestartAction)
+	MustRegisterAction(ActionLabel, newMarkAction(ActionLabel, "labels"))
+	MustRegisterAction(ActionAnnotate, newMarkAction(ActionAnnotate, "annotations"))
+	MustRegisterAction(ActionExecWebhook, newWebhookAction)
+}
+
+// RegisterAction makes an Action available to rules by name
+// Forks and library users call it from an init function to add their own remediation strategies
+func RegisterAction(name string, factory ActionFactory) error {
+	actionsMu.Lock()
+	defer actionsMu.Unlock()
+	if name == "" {
+		return fmt.Errorf("invalid action name %q", name)
+	}
+	if _, ok := actions[name]; ok {
+		return fmt.Errorf("action %q is already registered", name)
+	}
+	actions[name] = factory
+	return nil
+}
+
+// MustRegisterAction is like RegisterAction but panics if the Action cannot be registered
+func MustRegisterAction(name string, factory ActionFactory) {
+	err := RegisterAction(name, factory)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// NewAction builds the registered Action named by spec.Action (ActionDelete when empty)
+func NewAction(spec ActionSpec) (Action, error) {
+	name := spec.Action
+	if name == "" {
+		name = ActionDelete
+	}
+	actionsMu.RLock()
+	factory, ok := actions[name]
+	actionsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown action %q: expected one of %v", name, registeredActions())
+	}
+	return factory(spec)
+}
+
+// registeredActions returns the sorted names of all registered actions
+func registeredActions() []string {
+	actionsMu.RLock()
+	defer actionsMu.RUnlock()
+	var names []string
+	for name := range actions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Remediate plans action for the Pod, then executes the Plan or only dry-runs it when dryRun is true
+func (c *kubeClient) Remediate(ctx context.Context, action Action, pod *PodDetails, dryRun bool) (*Result, error) {
+	plan, err := action.Plan(ctx, c.clientSet, pod)
+	if err != nil {
+		return nil, err
+	}
+	if plan.Time.IsZero() {
+		plan.Time = c.now()
+	}
+	if dryRun {
+		return action.DryRun(ctx, c.clientSet, plan)
+	}
+	return action.Execute(ctx, c.clientSet, plan)
+}
+
+// dryRunResult returns the Result of a Plan that has not been executed
+func dryRunResult(plan *Plan) (*Result, error) {
+	return &Result{Plan: plan, DryRun: true, Outcome: OutcomePlanned}, nil
+}
+
+// deleteAction deletes the Pod
+type deleteAction struct {
+	policy DeletePolicy
+}
+
+func newDeleteAction(spec ActionSpec) (Action, error) {
+	return &deleteAction{policy: spec.DeletePolicy}, spec.DeletePolicy.Validate()
+}
+
+func (a *deleteAction) Name() string {
+	return ActionDelete
+}
+
+func (a *deleteAction) Permissions(namespace string) []Permission {
+	return []Permission{{Verb: "delete", Resource: "pods", Namespace: namespace}}
+}
+
+func (a *deleteAction) Plan(_ context.Context, _ kubernetes.Interface, pod *PodDetails) (*Plan, error) {
+	return &Plan{
+		Action:      ActionDelete,
+		Pod:         pod,
+		Target:      "Pod/" + pod.PodName,
+		Description: fmt.Sprintf("delete Pod %s/%s (%s)", pod.PodNamespace, pod.PodName, a.policy),
+	}, nil
+}
+
+func (a *deleteAction) Execute(ctx context.Context, clientSet kubernetes.Interface, plan *Plan) (*Result, error) {
+	err := deletePod(ctx, clientSet, plan.Pod.PodName, plan.Pod.PodNamespace, a.policy)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Plan: plan, Outcome: "deleted"}, nil
+}
+
+func (a *deleteAction) DryRun(_ context.Context, _ kubernetes.Interface, plan *Plan) (*Result, error) {
+	return dryRunResult(plan)
+}
+
+// evictAction evicts the Pod through the Eviction API so PodDisruptionBudgets are respected
+type evictAction struct {
+	policy DeletePolicy
+}
+
+func newEvictAction(spec ActionSpec) (Action, error) {
+	return &evictAction{policy: spec.DeletePolicy}, spec.DeletePolicy.Validate()
+}
+
+func (a *evictAction) Name() string {
+	return ActionEvict
+}
+
+func (a *evictAction) Permissions(namespace string) []Permission {
+	return []Permission{{Verb: "create", Resource: "pods", Subresource: "eviction", Namespace: namespace}}
+}
+
+func (a *evictAction) Plan(_ context.Context, _ kubernetes.Interface, pod *PodDetails) (*Plan, error) {
+	return &Plan{
+		Action:      ActionEvict,
+		Pod:         pod,
+		Target:      "Pod/" + pod.PodName,
+		Description: fmt.Sprintf("evict Pod %s/%s (%s)", pod.PodNamespace, pod.PodName, a.policy),
+	}, nil
+}
+
+func (a *evictAction) Execute(ctx context.Context, clientSet kubernetes.Interface, plan *Plan) (*Result, error) {
+	opts := a.policy.deleteOptions()
+	err := clientSet.PolicyV1().Evictions(plan.Pod.PodNamespace).Evict(ctx, &policyv1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      plan.Pod.PodName,
+			Namespace: plan.Pod.PodNamespace,
+		},
+		DeleteOptions: &opts,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Could not evict Pod %s/%s: %w", plan.Pod.PodNamespace, plan.Pod.PodName, err)
+	}
+	return &Result{Plan: plan, Outcome: "evicted"}, nil
+}
+
+func (a *evictAction) DryRun(_ context.Context, _ kubernetes.Interface, plan *Plan) (*Result, error) {
+	return dryRunResult(plan)
+}
+
+// rolloutRestartAction restarts the Deployment, StatefulSet or DaemonSet that owns the Pod
+// the same way kubectl rollout restart does
+type rolloutRestartAction struct{}
+
+func newRolloutRestartAction(ActionSpec) (Action, error) {
+	return &rolloutRestartAction{}, nil
+}
+
+func (a *rolloutRestartAction) Name() string {
+	return ActionRolloutRestart
+}
+
+// Permissions include reading the owner chain the restarted owner is found with
+func (a *rolloutRestartAction) Permissions(namespace string) []Permission {
+	return inNamespace([]Permission{
+		{Verb: "get", Group: "apps", Resource: "replicasets"},
+		{Verb: "get", Group: "batch", Resource: "jobs"},
+		{Verb: "patch", Group: "apps", Resource: "deployments"},
+		{Verb: "patch", Group: "apps", Resource: "statefulsets"},
+		{Verb: "patch", Group: "apps", Resource: "daemonsets"},
+	}, namespace)
+}
+
+func (a *rolloutRestartAction) Plan(ctx context.Context, clientSet kubernetes.Interface, pod *PodDetails) (*Plan, error) {
+	chain, err := OwnerChain(ctx, clientSet, pod)
+	if err != nil {
+		return nil, err
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("%w: %s/%s", ErrNoOwner, pod.PodNamespace, pod.PodName)
+	}
+	owner := chain[len(chain)-1]
+	switch owner.Kind {
+	case "Deployment", "StatefulSet", "DaemonSet":
+	default:
+		return nil, fmt.Errorf("Cannot rollout restart %s owning Pod %s/%s", owner, pod.PodNamespace, pod.PodName)
+	}
+	return &Plan{
+		Action:      ActionRolloutRestart,
+		Pod:         pod,
+		Target:      owner.String(),
+		Description: fmt.Sprintf("rollout restart %s in namespace %s", owner, pod.PodNamespace),
+	}, nil
+}
+
+func (a *rolloutRestartAction) Execute(ctx context.Context, clientSet kubernetes.Interface, plan *Plan) (*Result, error) {
+	restartedAt := plan.Time
+	if restartedAt.IsZero() {
+		restartedAt = time.Now()
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]string{
+						restartedAtAnnotation: restartedAt.Format(time.RFC3339),
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	kind, name, _ := strings.Cut(plan.Target, "/")
+	namespace := plan.Pod.PodNamespace
+	apps := clientSet.AppsV1()
+	switch kind {
+	case "Deployment":
+		_, err = apps.Deployments(namespace).Patch(ctx, name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	case "StatefulSet":
+		_, err = apps.StatefulSets(namespace).Patch(ctx, name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	case "DaemonSet":
+		_, err = apps.DaemonSets(namespace).Patch(ctx, name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Could not rollout restart %s in namespace %s: %w", plan.Target, namespace, err)
+	}
+	return &Result{Plan: plan, Outcome: "restarted"}, nil
+}
+
+func (a *rolloutRestartAction) DryRun(_ context.Context, _ kubernetes.Interface, plan *Plan) (*Result, error) {
+	return dryRunResult(plan)
+}
+
+// markAction only adds labels (label action) or annotations (annotate action) to the Pod so another system can act on it
+// field is the metadata field it patches; the other field is left untouched
+type markAction struct {
+	name   string
+	field  string
+	values map[string]string
+}
+
+func newMarkAction(name, field string) ActionFactory {
+	return func(spec ActionSpec) (Action, error) {
+		values, other, otherField, otherAction := spec.Labels, spec.Annotations, "annotations", ActionAnnotate
+		if field == "annotations" {
+			values, other, otherField, otherAction = spec.Annotations, spec.Labels, "labels", ActionLabel
+		}
+		if len(values) == 0 {
+			return nil, fmt.Errorf("action %s requires %s", name, field)
+		}
+		if len(other) > 0 {
+			return nil, fmt.Errorf("action %s does not add %s: use the %s action", name, otherField, otherAction)
+		}
+		return &markAction{name: name, field: field, values: values}, nil
+	}
+}
+
+func (a *markAction) Name() string {
+	return a.name
+}
+
+func (a *markAction) Permissions(namespace string) []Permission {
+	return []Permission{{Verb: "patch", Resource: "pods", Namespace: namespace}}
+}
+
+func (a *markAction) Plan(_ context.Context, _ kubernetes.Interface, pod *PodDetails) (*Plan, error) {
+	return &Plan{
+		Action:      a.name,
+		Pod:         pod,
+		Target:      "Pod/" + pod.PodName,
+		Description: fmt.Sprintf("%s Pod %s/%s with %v", a.name, pod.PodNamespace, pod.PodName, a.values),
+	}, nil
+}
+
+// Execute merges the values into the Pod metadata field
+// The patch only carries that field, since a merge patch removes the fields set to null
+func (a *markAction) Execute(ctx context.Context, clientSet kubernetes.Interface, plan *Plan) (*Result, error) {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			a.field: a.values,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	_, err = clientSet.CoreV1().Pods(plan.Pod.PodNamespace).Patch(
+		ctx, plan.Pod.PodName, types.MergePatchType, patch, metav1.PatchOptions{},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Could not patch Pod %s/%s: %w", plan.Pod.PodNamespace, plan.Pod.PodName, err)
+	}
+	return &Result{Plan: plan, Outcome: "marked"}, nil
+}
+
+func (a *markAction) DryRun(_ context.Context, _ kubernetes.Interface, plan *Plan) (*Result, error) {
+	return dryRunResult(plan)
+}
+
+// webhookAction hands the remediation over to an external system by POSTing the Pod to a webhook
+type webhookAction struct {
+	url    string
+	client *http.Client
+}
+
+// WebhookRequest is the JSON body the exec-webhook action POSTs
+type WebhookRequest struct {
+	Action    string    `json:"action"`
+	Pod       string    `json:"pod"`
+	Namespace string    `json:"namespace"`
+	UID       types.UID `json:"uid"`
+	Owner     string    `json:"owner,omitempty"`
+	Phase     string    `json:"phase"`
+}
+
+func newWebhookAction(spec ActionSpec) (Action, error) {
+	u, err := url.Parse(spec.WebhookURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("action %s requires an http(s) webhookURL: %q", ActionExecWebhook, spec.WebhookURL)
+	}
+	return &webhookAction{url: spec.WebhookURL, client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+func (a *webhookAction) Name() string {
+	return ActionExecWebhook
+}
+
+func (a *webhookAction) Plan(_ context.Context, _ kubernetes.Interface, pod *PodDetails) (*Plan, error) {
+	return &Plan{
+		Action:      ActionExecWebhook,
+		Pod:         pod,
+		Target:      "Pod/" + pod.PodName,
+		Description: fmt.Sprintf("POST Pod %s/%s to %s", pod.PodNamespace, pod.PodName, a.url),
+	}, nil
+}
+
+func (a *webhookAction) Execute(ctx context.Context, _ kubernetes.Interface, plan *Plan) (*Result, error) {
+	body, err := json.Marshal(WebhookRequest{
+		Action:    ActionExecWebhook,
+		Pod:       plan.Pod.PodName,
+		Namespace: plan.Pod.PodNamespace,
+		UID:       plan.Pod.UID,
+		Owner:     plan.Pod.Owner(),
+		Phase:     string(plan.Pod.Phase),
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Could not call webhook for Pod %s/%s: %w", plan.Pod.PodNamespace, plan.Pod.PodName, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("Webhook for Pod %s/%s returned %s", plan.Pod.PodNamespace, plan.Pod.PodName, resp.Status)
+	}
+	return &Result{Plan: plan, Outcome: "delegated"}, nil
+}
+
+func (a *webhookAction) DryRun(_ context.Context, _ kubernetes.Interface, plan *Plan) (*Result, error) {
+	return dryRunResult(plan)
+}
@@ -0,0 +1,250 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestNewAction(t *testing.T) {
+	negativeGracePeriod := int64(-1)
+
+	tests := map[string]struct {
+		spec         ActionSpec
+		expectedName string
+		expectError  bool
+	}{
+		"Default action deletes Pods":          {spec: ActionSpec{}, expectedName: ActionDelete},
+		"Evict action":                         {spec: ActionSpec{Action: ActionEvict}, expectedName: ActionEvict},
+		"Rollout restart action":               {spec: ActionSpec{Action: ActionRolloutRestart}, expectedName: ActionRolloutRestart},
+		"Label action":                         {spec: ActionSpec{Action: ActionLabel, Labels: map[string]string{"a": "b"}}, expectedName: ActionLabel},
+		"Webhook action":                       {spec: ActionSpec{Action: ActionExecWebhook, WebhookURL: "https://example.com/hook"}, expectedName: ActionExecWebhook},
+		"Reject unknown action":                {spec: ActionSpec{Action: "reboot-node"}, expectError: true},
+		"Reject label action without labels":   {spec: ActionSpec{Action: ActionLabel}, expectError: true},
+		"Annotate action":                      {spec: ActionSpec{Action: ActionAnnotate, Annotations: map[string]string{"a": "b"}}, expectedName: ActionAnnotate},
+		"Reject annotate action with labels":   {spec: ActionSpec{Action: ActionAnnotate, Labels: map[string]string{"a": "b"}}, expectError: true},
+		"Reject label action with annotations": {spec: ActionSpec{Action: ActionLabel, Labels: map[string]string{"a": "b"}, Annotations: map[string]string{"c": "d"}}, expectError: true},
+		"Reject webhook action without URL":    {spec: ActionSpec{Action: ActionExecWebhook}, expectError: true},
+		"Reject delete with negative grace":    {spec: ActionSpec{DeletePolicy: DeletePolicy{GracePeriodSeconds: &negativeGracePeriod}}, expectError: true},
+		"Reject webhook action with a bad URL": {spec: ActionSpec{Action: ActionExecWebhook, WebhookURL: "ftp://example.com"}, expectError: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			action, err := NewAction(tc.spec)
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedName, action.Name())
+		})
+	}
+}
+
+func TestRemediate(t *testing.T) {
+	controller := true
+	owned := func(pod *v1.Pod, kind, name string) *v1.Pod {
+		pod.OwnerReferences = []metav1.OwnerReference{{Kind: kind, Name: name, Controller: &controller}}
+		return pod
+	}
+	marked := func(pod *v1.Pod) *v1.Pod {
+		pod.Labels = map[string]string{"app": "foo"}
+		pod.Annotations = map[string]string{"team": "bar"}
+		return pod
+	}
+	replicaSet := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "foo-abc",
+			Namespace:       "default",
+			OwnerReferences: []metav1.OwnerReference{{Kind: "Deployment", Name: "foo", Controller: &controller}},
+		},
+	}
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"}}
+
+	tests := map[string]struct {
+		mockedObjects   []runtime.Object
+		spec            ActionSpec
+		dryRun          bool
+		expectedOutcome string
+		expectedTarget  string
+		expectError     bool
+		validate        func(t *testing.T, clientSet *fake.Clientset)
+	}{
+		"Delete Pod": {
+			mockedObjects:   []runtime.Object{owned(makePod("foo", "default", 1, v1.PodPending, "uid1"), "ReplicaSet", "foo-abc")},
+			spec:            ActionSpec{Action: ActionDelete},
+			expectedOutcome: "deleted",
+			expectedTarget:  "Pod/foo",
+			validate: func(t *testing.T, clientSet *fake.Clientset) {
+				_, err := clientSet.CoreV1().Pods("default").Get(context.TODO(), "foo", metav1.GetOptions{})
+				assert.True(t, apierrors.IsNotFound(err), "Expected Pod to be deleted")
+			},
+		},
+		"Dry run does not delete Pod": {
+			mockedObjects:   []runtime.Object{owned(makePod("foo", "default", 1, v1.PodPending, "uid1"), "ReplicaSet", "foo-abc")},
+			spec:            ActionSpec{Action: ActionDelete},
+			dryRun:          true,
+			expectedOutcome: OutcomePlanned,
+			expectedTarget:  "Pod/foo",
+			validate: func(t *testing.T, clientSet *fake.Clientset) {
+				_, err := clientSet.CoreV1().Pods("default").Get(context.TODO(), "foo", metav1.GetOptions{})
+				assert.NoError(t, err, "Expected Pod to still exist in dry run mode")
+			},
+		},
+		"Evict Pod": {
+			mockedObjects:   []runtime.Object{owned(makePod("foo", "default", 1, v1.PodPending, "uid1"), "ReplicaSet", "foo-abc")},
+			spec:            ActionSpec{Action: ActionEvict},
+			expectedOutcome: "evicted",
+			expectedTarget:  "Pod/foo",
+			validate: func(t *testing.T, clientSet *fake.Clientset) {
+				var evictions int
+				for _, action := range clientSet.Actions() {
+					if action.Matches("create", "pods") && action.GetSubresource() == "eviction" {
+						evictions++
+					}
+				}
+				assert.Equal(t, 1, evictions)
+			},
+		},
+		"Rollout restart Deployment": {
+			mockedObjects: []runtime.Object{
+				owned(makePod("foo", "default", 1, v1.PodPending, "uid1"), "ReplicaSet", "foo-abc"),
+				replicaSet,
+				deployment,
+			},
+			spec:            ActionSpec{Action: ActionRolloutRestart},
+			expectedOutcome: "restarted",
+			expectedTarget:  "Deployment/foo",
+			validate: func(t *testing.T, clientSet *fake.Clientset) {
+				dep, err := clientSet.AppsV1().Deployments("default").Get(context.TODO(), "foo", metav1.GetOptions{})
+				require.NoError(t, err)
+				assert.Equal(t, testNow.Format(time.RFC3339), dep.Spec.Template.Annotations[restartedAtAnnotation])
+			},
+		},
+		"Rollout restart rejects bare Pod": {
+			mockedObjects: []runtime.Object{makePod("foo", "default", 1, v1.PodPending, "uid1")},
+			spec:          ActionSpec{Action: ActionRolloutRestart},
+			expectError:   true,
+		},
+		"Rollout restart rejects Job": {
+			mockedObjects: []runtime.Object{owned(makePod("foo", "default", 1, v1.PodPending, "uid1"), "Node", "node1")},
+			spec:          ActionSpec{Action: ActionRolloutRestart},
+			expectError:   true,
+		},
+		"Label Pod": {
+			mockedObjects:   []runtime.Object{marked(owned(makePod("foo", "default", 1, v1.PodPending, "uid1"), "ReplicaSet", "foo-abc"))},
+			spec:            ActionSpec{Action: ActionLabel, Labels: map[string]string{"pod-restarter/failing": "true"}},
+			expectedOutcome: "marked",
+			expectedTarget:  "Pod/foo",
+			validate: func(t *testing.T, clientSet *fake.Clientset) {
+				pod, err := clientSet.CoreV1().Pods("default").Get(context.TODO(), "foo", metav1.GetOptions{})
+				require.NoError(t, err)
+				assert.Equal(t, map[string]string{"app": "foo", "pod-restarter/failing": "true"}, pod.Labels)
+				assert.Equal(t, map[string]string{"team": "bar"}, pod.Annotations)
+			},
+		},
+		"Annotate Pod": {
+			mockedObjects:   []runtime.Object{marked(owned(makePod("foo", "default", 1, v1.PodPending, "uid1"), "ReplicaSet", "foo-abc"))},
+			spec:            ActionSpec{Action: ActionAnnotate, Annotations: map[string]string{"pod-restarter/reason": "BackOff"}},
+			expectedOutcome: "marked",
+			expectedTarget:  "Pod/foo",
+			validate: func(t *testing.T, clientSet *fake.Clientset) {
+				pod, err := clientSet.CoreV1().Pods("default").Get(context.TODO(), "foo", metav1.GetOptions{})
+				require.NoError(t, err)
+				assert.Equal(t, map[string]string{"app": "foo"}, pod.Labels)
+				assert.Equal(t, map[string]string{"team": "bar", "pod-restarter/reason": "BackOff"}, pod.Annotations)
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			clientSet := fake.NewSimpleClientset(tc.mockedObjects...)
+			clt := NewK8sClientFromClientSet(clientSet, 0, testingclock.NewFakeClock(testNow))
+			pod, err := clt.GetPodDetails(context.TODO(), "foo", "default")
+			require.NoError(t, err)
+			action, err := NewAction(tc.spec)
+			require.NoError(t, err)
+
+			result, err := clt.Remediate(context.TODO(), action, pod, tc.dryRun)
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedOutcome, result.Outcome)
+			assert.Equal(t, testNow, result.Plan.Time)
+			assert.Equal(t, tc.expectedTarget, result.Plan.Target)
+			assert.Equal(t, tc.dryRun, result.DryRun)
+			if tc.validate != nil {
+				tc.validate(t, clientSet)
+			}
+		})
+	}
+}
+
+func TestRemediateWebhook(t *testing.T) {
+	var requests []WebhookRequest
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req WebhookRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		requests = append(requests, req)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	clt := kubeClient{clientSet: fake.NewSimpleClientset()}
+	pod := &PodDetails{PodName: "foo", PodNamespace: "default", UID: "uid1", Phase: v1.PodPending}
+	action, err := NewAction(ActionSpec{Action: ActionExecWebhook, WebhookURL: server.URL})
+	require.NoError(t, err)
+
+	// dry run does not call the webhook
+	result, err := clt.Remediate(context.TODO(), action, pod, true)
+	require.NoError(t, err)
+	assert.Equal(t, OutcomePlanned, result.Outcome)
+	assert.Empty(t, requests)
+
+	result, err = clt.Remediate(context.TODO(), action, pod, false)
+	require.NoError(t, err)
+	assert.Equal(t, "delegated", result.Outcome)
+	require.Len(t, requests, 1)
+	assert.Equal(t, WebhookRequest{Action: ActionExecWebhook, Pod: "foo", Namespace: "default", UID: "uid1", Phase: "Pending"}, requests[0])
+
+	// non 2xx responses are errors
+	status = http.StatusInternalServerError
+	_, err = clt.Remediate(context.TODO(), action, pod, false)
+	assert.Error(t, err)
+}
+
+func TestRemediateEvictBlockedByPDB(t *testing.T) {
+	clientSet := fake.NewSimpleClientset(makePod("foo", "default", 1, v1.PodPending, "uid1"))
+	clientSet.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		return true, nil, apierrors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget.", 0)
+	})
+	clt := kubeClient{clientSet: clientSet}
+	action, err := NewAction(ActionSpec{Action: ActionEvict})
+	require.NoError(t, err)
+
+	_, err = clt.Remediate(context.TODO(), action, &PodDetails{PodName: "foo", PodNamespace: "default"}, false)
+	require.Error(t, err)
+	assert.True(t, apierrors.IsTooManyRequests(err))
+}
@@ -0,0 +1,159 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// Check is a pre-deletion safety check run by PodChecks
+// Check returns nil when the Pod can be restarted, or an error explaining why it must not be restarted
+type Check interface {
+	Name() string
+	Check(ctx context.Context, clientSet kubernetes.Interface, pod *PodDetails) error
+}
+
+// CheckFunc is the signature of the function behind a Check built with NewCheck
+type CheckFunc func(ctx context.Context, clientSet kubernetes.Interface, pod *PodDetails) error
+
+// Outcomes of a Check reported in CheckResult
+const (
+	CheckPassed  = "passed"
+	CheckFailed  = "failed"
+	CheckSkipped = "skipped"
+)
+
+// CheckResult holds the outcome of a single Check and the reason it failed
+type CheckResult struct {
+	Name    string
+	Outcome string
+	Reason  error
+}
+
+// names of the built-in checks
+const (
+	CheckHasOwner       = "has-owner"
+	CheckNotTerminating = "not-terminating"
+	CheckUnhealthy      = "unhealthy"
+)
+
+// checkExists is reported as the first CheckResult of every Verdict
+const checkExists = "exists"
+
+// DefaultChecks holds the names of the checks run when a rule does not configure its own pipeline
+var DefaultChecks = []string{CheckHasOwner, CheckNotTerminating, CheckUnhealthy}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Check{}
+)
+
+func init() {
+	for _, check := range []Check{
+		NewCheck(CheckHasOwner, func(_ context.Context, _ kubernetes.Interface, pod *PodDetails) error {
+			return pod.verifyPodHasOwner()
+		}),
+		NewCheck(CheckNotTerminating, func(_ context.Context, _ kubernetes.Interface, pod *PodDetails) error {
+			return pod.verifyPodScheduledToBeDeleted()
+		}),
+		NewCheck(CheckUnhealthy, func(_ context.Context, _ kubernetes.Interface, pod *PodDetails) error {
+			return pod.verifyPodStatus()
+		}),
+	} {
+		MustRegisterCheck(check)
+	}
+}
+
+// NewCheck returns a Check named name that runs fn
+func NewCheck(name string, fn CheckFunc) Check {
+	return &funcCheck{name: name, fn: fn}
+}
+
+type funcCheck struct {
+	name string
+	fn   CheckFunc
+}
+
+func (f *funcCheck) Name() string {
+	return f.name
+}
+
+func (f *funcCheck) Check(ctx context.Context, clientSet kubernetes.Interface, pod *PodDetails) error {
+	return f.fn(ctx, clientSet, pod)
+}
+
+// RegisterCheck makes a Check available to rules by name
+// Forks and library users call it from an init function to add their own checks
+func RegisterCheck(check Check) error {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if check.Name() == "" || check.Name() == checkExists {
+		return fmt.Errorf("invalid check name %q", check.Name())
+	}
+	if _, ok := registry[check.Name()]; ok {
+		return fmt.Errorf("check %q is already registered", check.Name())
+	}
+	registry[check.Name()] = check
+	return nil
+}
+
+// MustRegisterCheck is like RegisterCheck but panics if the Check cannot be registered
+func MustRegisterCheck(check Check) {
+	err := RegisterCheck(check)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// LookupChecks returns the registered checks in the order of names
+func LookupChecks(names []string) ([]Check, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	var pipeline []Check
+	for _, name := range names {
+		check, ok := registry[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown check %q: expected one of %v", name, registeredChecks())
+		}
+		pipeline = append(pipeline, check)
+	}
+	return pipeline, nil
+}
+
+// registeredChecks returns the sorted names of all registered checks
+func registeredChecks() []string {
+	var names []string
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// String returns the check name, outcome and reason in a format suitable for logging
+func (r CheckResult) String() string {
+	if r.Reason != nil {
+		return fmt.Sprintf("%s: %s (%v)", r.Name, r.Outcome, r.Reason)
+	}
+	return fmt.Sprintf("%s: %s", r.Name, r.Outcome)
+}
+
+// MarshalJSON encodes the reason as a string so CheckResult can be logged and reported
+func (r CheckResult) MarshalJSON() ([]byte, error) {
+	result := struct {
+		Name    string `json:"name"`
+		Outcome string `json:"outcome"`
+		Reason  string `json:"reason,omitempty"`
+	}{
+		Name:    r.Name,
+		Outcome: r.Outcome,
+	}
+	if r.Reason != nil {
+		result.Reason = r.Reason.Error()
+	}
+	return json.Marshal(result)
+}
@@ -0,0 +1,133 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	types "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var errOnlyReplica = errors.New("Pod is the only replica")
+
+// notOnlyReplica is an example of a user defined check that uses the API
+var notOnlyReplica = NewCheck("not-only-replica", func(ctx context.Context, clientSet kubernetes.Interface, pod *PodDetails) error {
+	pods, err := clientSet.CoreV1().Pods(pod.PodNamespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	if len(pods.Items) < 2 {
+		return fmt.Errorf("%w: %s/%s", errOnlyReplica, pod.PodNamespace, pod.PodName)
+	}
+	return nil
+})
+
+func TestRegisterCheck(t *testing.T) {
+	check := NewCheck("test-register", func(context.Context, kubernetes.Interface, *PodDetails) error { return nil })
+	require.NoError(t, RegisterCheck(check))
+	assert.Error(t, RegisterCheck(check), "Expected an error registering a check twice")
+	assert.Error(t, RegisterCheck(NewCheck("", nil)), "Expected an error registering a check without name")
+	assert.Error(t, RegisterCheck(NewCheck(checkExists, nil)), "Expected an error registering a reserved check name")
+
+	pipeline, err := LookupChecks([]string{CheckUnhealthy, "test-register"})
+	require.NoError(t, err)
+	require.Len(t, pipeline, 2)
+	assert.Equal(t, CheckUnhealthy, pipeline[0].Name())
+	assert.Equal(t, "test-register", pipeline[1].Name())
+
+	_, err = LookupChecks([]string{"does-not-exist"})
+	assert.Error(t, err)
+}
+
+func TestPodChecksPipeline(t *testing.T) {
+	controller := true
+	ownedPod := func(name string, phase v1.PodPhase) *v1.Pod {
+		pod := makePod(name, "default", 1, phase, types.UID("uid-"+name))
+		pod.OwnerReferences = []metav1.OwnerReference{
+			{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "foo-abc", Controller: &controller},
+		}
+		return pod
+	}
+
+	tests := map[string]struct {
+		mockedPods       []runtime.Object
+		checks           []Check
+		expectRestart    bool
+		expectedOutcomes []string
+	}{
+		"Verify every check passes": {
+			mockedPods:       []runtime.Object{ownedPod("foo", v1.PodPending), ownedPod("bar", v1.PodRunning)},
+			checks:           append(mustLookupChecks(t, DefaultChecks), notOnlyReplica),
+			expectRestart:    true,
+			expectedOutcomes: []string{"exists: passed", "has-owner: passed", "not-terminating: passed", "unhealthy: passed", "not-only-replica: passed"},
+		},
+		"Verify custom check blocks the restart": {
+			mockedPods:       []runtime.Object{ownedPod("foo", v1.PodPending)},
+			checks:           append(mustLookupChecks(t, DefaultChecks), notOnlyReplica),
+			expectRestart:    false,
+			expectedOutcomes: []string{"exists: passed", "has-owner: passed", "not-terminating: passed", "unhealthy: passed", "not-only-replica: failed"},
+		},
+		"Verify checks after a failure are skipped": {
+			mockedPods:       []runtime.Object{ownedPod("foo", v1.PodSucceeded)},
+			checks:           append(mustLookupChecks(t, []string{CheckUnhealthy}), notOnlyReplica),
+			expectRestart:    false,
+			expectedOutcomes: []string{"exists: passed", "unhealthy: failed", "not-only-replica: skipped"},
+		},
+		"Verify checks are skipped for a missing Pod": {
+			mockedPods:       []runtime.Object{},
+			checks:           mustLookupChecks(t, []string{CheckHasOwner}),
+			expectRestart:    false,
+			expectedOutcomes: []string{"exists: failed", "has-owner: skipped"},
+		},
+		"Verify an empty pipeline only checks the Pod exists": {
+			mockedPods:       []runtime.Object{makePod("foo", "default", 1, v1.PodRunning, "uid1")},
+			checks:           []Check{},
+			expectRestart:    true,
+			expectedOutcomes: []string{"exists: passed"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var clt kubeClient
+			clt.clientSet = fake.NewSimpleClientset(tc.mockedPods...)
+
+			verdict, err := clt.PodChecks(context.TODO(), "foo", "default", tc.checks)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectRestart, verdict.Restart)
+
+			var outcomes []string
+			for _, result := range verdict.Checks {
+				outcomes = append(outcomes, result.Name+": "+result.Outcome)
+			}
+			assert.Equal(t, tc.expectedOutcomes, outcomes)
+		})
+	}
+}
+
+func TestCheckResultMarshalJSON(t *testing.T) {
+	data, err := json.Marshal([]CheckResult{
+		{Name: CheckHasOwner, Outcome: CheckFailed, Reason: fmt.Errorf("%w: default/foo", ErrNoOwner)},
+		{Name: CheckUnhealthy, Outcome: CheckSkipped},
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"name": "has-owner", "outcome": "failed", "reason": "Pod does not have owner/controller: default/foo"},
+		{"name": "unhealthy", "outcome": "skipped"}
+	]`, string(data))
+}
+
+func mustLookupChecks(t *testing.T, names []string) []Check {
+	checks, err := LookupChecks(names)
+	require.NoError(t, err)
+	return checks
+}
@@ -0,0 +1,95 @@
+package kubernetes
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+)
+
+// EventCursor is a high-water mark of the Events processed by a rule, so that no Event is processed twice or missed between cycles
+// It remembers the LastTimestamp and resourceVersion of every Event UID it has seen
+// On the first listing of a namespace only Events seen within the lookback window are processed, the older ones are only recorded;
+// on the next listings every Event that is new, or that was seen again since it was processed, is processed
+// An EventCursor is not safe for concurrent use
+type EventCursor struct {
+	lookback time.Duration
+	// started holds the namespaces listed at least once ("" for all namespaces)
+	started map[string]bool
+	marks   map[types.UID]eventMark
+}
+
+// eventMark is the last processed version of an Event
+type eventMark struct {
+	namespace       string
+	lastTimestamp   time.Time
+	resourceVersion string
+}
+
+// NewEventCursor returns an empty cursor that processes the Events seen within lookback of the first listing
+func NewEventCursor(lookback time.Duration) *EventCursor {
+	return &EventCursor{
+		lookback: lookback,
+		started:  make(map[string]bool),
+		marks:    make(map[types.UID]eventMark),
+	}
+}
+
+// advance returns the Events that have not been processed yet and moves the cursor past them
+// events are the Events of the namespaces that were listed successfully ("" for all namespaces);
+// only these namespaces move, the marks of the namespaces that could not be listed are kept for the next listing
+// Events of the listed namespaces that are not listed anymore (eg: expired) are forgotten
+func (c *EventCursor) advance(events []PodEvent, listed []string, now time.Time) []PodEvent {
+	eventMinAge := now.Add(-c.lookback)
+
+	marks := make(map[types.UID]eventMark, len(c.marks))
+	for uid, mark := range c.marks {
+		if !covers(listed, mark.namespace) {
+			marks[uid] = mark
+		}
+	}
+
+	var newEvents []PodEvent
+	for _, event := range events {
+		mark := eventMark{namespace: event.PodNamespace, lastTimestamp: event.LastTimestamp, resourceVersion: event.EventResourceVersion}
+		prev, seen := c.marks[event.EventUID]
+		marks[event.EventUID] = mark
+
+		switch {
+		case seen && !mark.after(prev):
+			continue
+		case !seen && !c.startedIn(event.PodNamespace) && event.LastTimestamp.Before(eventMinAge):
+			continue
+		}
+		newEvents = append(newEvents, event)
+	}
+	c.marks = marks
+	for _, namespace := range listed {
+		c.started[namespace] = true
+	}
+	return newEvents
+}
+
+// startedIn returns true if the namespace was listed before, on its own or with all namespaces
+func (c *EventCursor) startedIn(namespace string) bool {
+	return c.started[metav1.NamespaceAll] || c.started[namespace]
+}
+
+// covers returns true if namespace is one of the listed namespaces, or all namespaces were listed
+func covers(listed []string, namespace string) bool {
+	for _, l := range listed {
+		if l == metav1.NamespaceAll || l == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// after returns true if the Event was seen again since prev was recorded
+// Events seen again within the same second only change their resourceVersion
+func (m eventMark) after(prev eventMark) bool {
+	if !m.lastTimestamp.Equal(prev.lastTimestamp) {
+		return m.lastTimestamp.After(prev.lastTimestamp)
+	}
+	return m.resourceVersion != "" && m.resourceVersion != prev.resourceVersion
+}
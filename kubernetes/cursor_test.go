@@ -0,0 +1,122 @@
+package kubernetes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+)
+
+func TestEventCursorAdvance(t *testing.T) {
+	event := func(uid, rv string, lastSeen time.Duration) PodEvent {
+		return PodEvent{
+			PodName:              "pod_" + uid,
+			EventUID:             types.UID(uid),
+			EventResourceVersion: rv,
+			LastTimestamp:        testNow.Add(lastSeen),
+		}
+	}
+
+	testCases := []struct {
+		testName       string
+		listings       [][]PodEvent
+		expectedEvents []PodEvent
+	}{
+		{
+			testName:       "Startup keeps Events within the lookback window",
+			listings:       [][]PodEvent{{event("a", "1", -time.Minute), event("b", "1", -10*time.Minute)}},
+			expectedEvents: []PodEvent{event("a", "1", -time.Minute)},
+		},
+		{
+			testName: "Events are processed once",
+			listings: [][]PodEvent{
+				{event("a", "1", -time.Minute)},
+				{event("a", "1", -time.Minute)},
+			},
+		},
+		{
+			testName: "Events older than the lookback window at startup are never processed",
+			listings: [][]PodEvent{
+				{event("b", "1", -10*time.Minute)},
+				{event("b", "1", -10*time.Minute)},
+			},
+		},
+		{
+			testName: "Events seen again later are processed again",
+			listings: [][]PodEvent{
+				{event("a", "1", -time.Minute)},
+				{event("a", "2", 0)},
+			},
+			expectedEvents: []PodEvent{event("a", "2", 0)},
+		},
+		{
+			testName: "Events seen again within the same second are processed again",
+			listings: [][]PodEvent{
+				{event("a", "1", 0)},
+				{event("a", "2", 0)},
+			},
+			expectedEvents: []PodEvent{event("a", "2", 0)},
+		},
+		{
+			testName: "New Events after startup are processed whatever their age",
+			listings: [][]PodEvent{
+				{},
+				{event("c", "1", -10*time.Minute)},
+			},
+			expectedEvents: []PodEvent{event("c", "1", -10*time.Minute)},
+		},
+		{
+			testName: "Expired Events are forgotten",
+			listings: [][]PodEvent{
+				{event("a", "1", -time.Minute)},
+				{},
+				{event("a", "1", -time.Minute)},
+			},
+			expectedEvents: []PodEvent{event("a", "1", -time.Minute)},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			cursor := NewEventCursor(5 * time.Minute)
+			var events []PodEvent
+			for _, listing := range test.listings {
+				events = cursor.advance(listing, []string{metav1.NamespaceAll}, testNow)
+			}
+			assert.Equal(t, test.expectedEvents, events)
+		})
+	}
+}
+
+func TestEventCursorListingErrors(t *testing.T) {
+	event := func(uid, namespace string, lastSeen time.Duration) PodEvent {
+		return PodEvent{
+			PodName:              "pod_" + uid,
+			PodNamespace:         namespace,
+			EventUID:             types.UID(uid),
+			EventResourceVersion: "1",
+			LastTimestamp:        testNow.Add(lastSeen),
+		}
+	}
+	cursor := NewEventCursor(5 * time.Minute)
+
+	// team-b fails to list at startup, so its old Events are only recorded once it is listed
+	events := cursor.advance([]PodEvent{event("a", "team-a", -time.Hour), event("b", "team-a", -time.Minute)}, []string{"team-a"}, testNow)
+	assert.Equal(t, []PodEvent{event("b", "team-a", -time.Minute)}, events)
+	events = cursor.advance([]PodEvent{event("a", "team-a", -time.Hour), event("c", "team-b", -time.Hour)}, []string{"team-a", "team-b"}, testNow)
+	assert.Empty(t, events)
+
+	// team-a fails to list, so its Events are not forgotten
+	events = cursor.advance([]PodEvent{event("c", "team-b", -time.Hour)}, []string{"team-b"}, testNow)
+	assert.Empty(t, events)
+	events = cursor.advance([]PodEvent{event("a", "team-a", -time.Hour), event("c", "team-b", -time.Hour)}, []string{"team-a", "team-b"}, testNow)
+	assert.Empty(t, events)
+
+	// every namespace fails to list
+	events = cursor.advance(nil, nil, testNow)
+	assert.Empty(t, events)
+	events = cursor.advance([]PodEvent{event("a", "team-a", -time.Hour), event("c", "team-b", -time.Hour)}, []string{metav1.NamespaceAll}, testNow)
+	assert.Empty(t, events)
+}
@@ -0,0 +1,22 @@
+package kubernetes
+
+import "errors"
+
+// Sentinel errors returned by the kubernetes package
+// They are wrapped with the Pod name and the underlying API error, so match them with errors.Is
+var (
+	// ErrPodNotFound means the Pod does not exist anymore
+	ErrPodNotFound = errors.New("Pod does not exist anymore")
+	// ErrNoOwner means the Pod has no owner/controller that would recreate it
+	ErrNoOwner = errors.New("Pod does not have owner/controller")
+	// ErrPodTerminating means the Pod has already been scheduled to be deleted
+	ErrPodTerminating = errors.New("Pod has already been scheduled to be deleted")
+	// ErrPodHealthy means the Pod is not in a failing state
+	ErrPodHealthy = errors.New("Pod is in a Healthy state")
+	// ErrBelowMinAvailable means remediating the Pod would leave its owner with fewer ready replicas than its minimum
+	ErrBelowMinAvailable = errors.New("owner would fall below its minimum available replicas")
+	// ErrForbidden means the credentials of pod-restarter lack the RBAC permission for an API call
+	ErrForbidden = errors.New("access forbidden")
+	// ErrImageMissing means the image a Pod fails to pull has never been pulled on any node, so restarting the Pod would not help
+	ErrImageMissing = errors.New("image genuinely missing")
+)
@@ -0,0 +1,151 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	e "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	types "k8s.io/apimachinery/pkg/types"
+)
+
+// Validate returns error if PodFilter has an invalid glob pattern or label selector
+func (f PodFilter) Validate() error {
+	for _, pattern := range append(f.Namespaces.Include, f.Namespaces.Exclude...) {
+		_, err := path.Match(pattern, "")
+		if err != nil {
+			return fmt.Errorf("invalid namespace pattern %q: %w", pattern, err)
+		}
+	}
+	if f.Namespaces.Scoped {
+		if len(f.Namespaces.Include) == 0 || hasGlob(f.Namespaces.Include) {
+			return errors.New("scoped namespaces require the names of the namespaces to include")
+		}
+		if f.Namespaces.Selector != "" {
+			return errors.New("scoped namespaces cannot be selected by label: Namespaces can only be listed cluster-wide")
+		}
+	}
+	_, err := labels.Parse(f.Namespaces.Selector)
+	if err != nil {
+		return fmt.Errorf("invalid namespace selector %q: %w", f.Namespaces.Selector, err)
+	}
+	_, err = labels.Parse(f.PodSelector)
+	if err != nil {
+		return fmt.Errorf("invalid pod selector %q: %w", f.PodSelector, err)
+	}
+	return nil
+}
+
+// namespaceScope holds the namespaces to query and a matcher for the namespaces to keep
+type namespaceScope struct {
+	namespaces []string
+	match      func(namespace string) bool
+}
+
+// resolveNamespaces returns the namespaces to query for Events and Pods
+// Namespaces are queried one by one only when Include holds literal names,
+// otherwise all namespaces are queried and filtered with the globs and the namespace label selector
+func (c *kubeClient) resolveNamespaces(ctx context.Context, f NamespaceFilter) (*namespaceScope, error) {
+	var selected map[string]bool
+	if f.Selector != "" {
+		namespaces, err := c.clientSet.CoreV1().Namespaces().List(
+			ctx,
+			metav1.ListOptions{LabelSelector: f.Selector},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("Could not get a list of Namespaces matching selector %s: %w", f.Selector, forbidden(err))
+		}
+		selected = make(map[string]bool)
+		for _, ns := range namespaces.Items {
+			selected[ns.Name] = true
+		}
+	}
+
+	scope := &namespaceScope{
+		namespaces: []string{metav1.NamespaceAll},
+		match: func(namespace string) bool {
+			if len(f.Include) > 0 && !matchesAny(f.Include, namespace) {
+				return false
+			}
+			if matchesAny(f.Exclude, namespace) {
+				return false
+			}
+			if selected != nil && !selected[namespace] {
+				return false
+			}
+			return true
+		},
+	}
+	if len(f.Include) > 0 && !hasGlob(f.Include) {
+		scope.namespaces = f.Include
+	}
+	return scope, nil
+}
+
+// MatchesFilter returns true if the Pod is in a namespace and has the labels selected by filter
+func (c *kubeClient) MatchesFilter(ctx context.Context, filter PodFilter, pod *PodDetails) (bool, error) {
+	scope, err := c.resolveNamespaces(ctx, filter.Namespaces)
+	if err != nil {
+		return false, err
+	}
+	if !scope.match(pod.PodNamespace) {
+		return false, nil
+	}
+	selector, err := labels.Parse(filter.PodSelector)
+	if err != nil {
+		return false, fmt.Errorf("invalid pod selector %q: %w", filter.PodSelector, err)
+	}
+	return selector.Matches(labels.Set(pod.Labels)), nil
+}
+
+// selectPods returns the UIDs of the Pods matching the label selector in the namespaces from scope
+// A namespace whose Pods cannot be listed does not stop the others: it is added to failed and the returned error joins their errors
+func (c *kubeClient) selectPods(ctx context.Context, scope *namespaceScope, selector string) (map[types.UID]bool, map[string]bool, error) {
+	selectedPods := make(map[types.UID]bool)
+	failed := make(map[string]bool)
+	var errs []error
+	for _, namespace := range scope.namespaces {
+		pods, err := c.listPods(ctx, namespace, selector)
+		if err != nil {
+			failed[namespace] = true
+			errs = append(errs, err)
+			continue
+		}
+		for _, pod := range *pods {
+			selectedPods[pod.UID] = true
+		}
+	}
+	return selectedPods, failed, errors.Join(errs...)
+}
+
+// forbidden wraps err with ErrForbidden when the API server denied the request
+func forbidden(err error) error {
+	if e.IsForbidden(err) {
+		return fmt.Errorf("%w: %w", ErrForbidden, err)
+	}
+	return err
+}
+
+// matchesAny returns true if name matches any of the glob patterns
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// hasGlob returns true if any of the patterns contains glob characters
+func hasGlob(patterns []string) bool {
+	for _, pattern := range patterns {
+		if strings.ContainsAny(pattern, `*?[\`) {
+			return true
+		}
+	}
+	return false
+}
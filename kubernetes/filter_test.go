@@ -0,0 +1,179 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestPodFilterValidate(t *testing.T) {
+	tests := map[string]struct {
+		filter      PodFilter
+		expectError bool
+	}{
+		"Verify empty filter is valid": {
+			filter: PodFilter{},
+		},
+		"Verify globs and selectors are valid": {
+			filter: PodFilter{
+				Namespaces: NamespaceFilter{
+					Include:  []string{"team-*"},
+					Exclude:  []string{"kube-system", "istio-?ystem"},
+					Selector: "team=platform",
+				},
+				PodSelector: "app in (nginx, busybox)",
+			},
+		},
+		"Verify invalid glob is rejected": {
+			filter:      PodFilter{Namespaces: NamespaceFilter{Exclude: []string{"team-["}}},
+			expectError: true,
+		},
+		"Verify invalid namespace selector is rejected": {
+			filter:      PodFilter{Namespaces: NamespaceFilter{Selector: "team in platform"}},
+			expectError: true,
+		},
+		"Verify invalid pod selector is rejected": {
+			filter:      PodFilter{PodSelector: "app in nginx"},
+			expectError: true,
+		},
+		"Verify scoped namespaces are valid": {
+			filter: PodFilter{Namespaces: NamespaceFilter{Include: []string{"team-a", "team-b"}, Scoped: true}},
+		},
+		"Verify scoped namespaces without include are rejected": {
+			filter:      PodFilter{Namespaces: NamespaceFilter{Scoped: true}},
+			expectError: true,
+		},
+		"Verify scoped namespaces with globs are rejected": {
+			filter:      PodFilter{Namespaces: NamespaceFilter{Include: []string{"team-*"}, Scoped: true}},
+			expectError: true,
+		},
+		"Verify scoped namespaces with selector are rejected": {
+			filter:      PodFilter{Namespaces: NamespaceFilter{Include: []string{"team-a"}, Selector: "team=a", Scoped: true}},
+			expectError: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := tc.filter.Validate()
+			if tc.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestResolveNamespaces(t *testing.T) {
+	tests := map[string]struct {
+		mockedNamespaces []runtime.Object
+		filter           NamespaceFilter
+		expectedQueries  []string
+		matching         []string
+		notMatching      []string
+	}{
+		"Verify all namespaces are queried by default": {
+			filter:          NamespaceFilter{},
+			expectedQueries: []string{metav1.NamespaceAll},
+			matching:        []string{"default", "kube-system"},
+		},
+		"Verify literal namespaces are queried one by one": {
+			filter:          NamespaceFilter{Include: []string{"default", "test"}, Exclude: []string{"test"}},
+			expectedQueries: []string{"default", "test"},
+			matching:        []string{"default"},
+			notMatching:     []string{"test", "kube-system"},
+		},
+		"Verify glob namespaces are matched across all namespaces": {
+			filter:          NamespaceFilter{Include: []string{"team-*"}, Exclude: []string{"team-legacy"}},
+			expectedQueries: []string{metav1.NamespaceAll},
+			matching:        []string{"team-a", "team-b"},
+			notMatching:     []string{"default", "team-legacy"},
+		},
+		"Verify namespaces are matched by label selector": {
+			mockedNamespaces: []runtime.Object{
+				makeNamespace("platform", map[string]string{"team": "platform"}),
+				makeNamespace("platform-dev", map[string]string{"team": "platform"}),
+				makeNamespace("default", nil),
+			},
+			filter:          NamespaceFilter{Selector: "team=platform", Exclude: []string{"*-dev"}},
+			expectedQueries: []string{metav1.NamespaceAll},
+			matching:        []string{"platform"},
+			notMatching:     []string{"default", "platform-dev"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var clt kubeClient
+			clt.clientSet = fake.NewSimpleClientset(tc.mockedNamespaces...)
+
+			scope, err := clt.resolveNamespaces(context.TODO(), tc.filter)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedQueries, scope.namespaces)
+			for _, ns := range tc.matching {
+				assert.True(t, scope.match(ns), "Expected namespace %s to match", ns)
+			}
+			for _, ns := range tc.notMatching {
+				assert.False(t, scope.match(ns), "Expected namespace %s not to match", ns)
+			}
+		})
+	}
+}
+
+func TestMatchesFilter(t *testing.T) {
+	mockedNamespaces := []runtime.Object{
+		makeNamespace("team-a", map[string]string{"team": "platform"}),
+		makeNamespace("team-b", map[string]string{"team": "data"}),
+	}
+
+	tests := map[string]struct {
+		filter   PodFilter
+		pod      PodDetails
+		expected bool
+	}{
+		"Verify every Pod matches an empty filter": {
+			pod:      PodDetails{PodName: "foo", PodNamespace: "default"},
+			expected: true,
+		},
+		"Verify Pods in excluded namespaces do not match": {
+			filter:   PodFilter{Namespaces: NamespaceFilter{Exclude: []string{"kube-*"}}},
+			pod:      PodDetails{PodName: "foo", PodNamespace: "kube-system"},
+			expected: false,
+		},
+		"Verify Pods in namespaces selected by label match": {
+			filter:   PodFilter{Namespaces: NamespaceFilter{Selector: "team=platform"}},
+			pod:      PodDetails{PodName: "foo", PodNamespace: "team-a"},
+			expected: true,
+		},
+		"Verify Pods in namespaces not selected by label do not match": {
+			filter:   PodFilter{Namespaces: NamespaceFilter{Selector: "team=platform"}},
+			pod:      PodDetails{PodName: "foo", PodNamespace: "team-b"},
+			expected: false,
+		},
+		"Verify Pods with selected labels match": {
+			filter:   PodFilter{PodSelector: "app=nginx"},
+			pod:      PodDetails{PodName: "foo", PodNamespace: "default", Labels: map[string]string{"app": "nginx"}},
+			expected: true,
+		},
+		"Verify Pods without selected labels do not match": {
+			filter:   PodFilter{PodSelector: "app=nginx"},
+			pod:      PodDetails{PodName: "foo", PodNamespace: "default", Labels: map[string]string{"app": "redis"}},
+			expected: false,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			clt := kubeClient{clientSet: fake.NewSimpleClientset(mockedNamespaces...)}
+			matches, err := clt.MatchesFilter(context.TODO(), tc.filter, &tc.pod)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, matches)
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+)
+
+// CheckMinAvailable is the name of the workload health guard added to the pipeline of rules with a minimum available
+const CheckMinAvailable = "min-available"
+
+// workload is the Deployment, StatefulSet or ReplicaSet guarded by the min-available check
+type workload struct {
+	Owner
+	replicas int
+	ready    int
+}
+
+// InFlight tracks the ready Pods that passed the min-available check during a cycle, per workload
+// They are about to be remediated, so they are not counted as available by the next checks of the cycle
+type InFlight struct {
+	mu     sync.Mutex
+	counts map[string]int
+	pods   map[types.UID]string
+}
+
+// NewInFlight returns an empty InFlight
+func NewInFlight() *InFlight {
+	return &InFlight{counts: map[string]int{}, pods: map[types.UID]string{}}
+}
+
+// Reset forgets the Pods of the previous cycle
+func (f *InFlight) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.counts = map[string]int{}
+	f.pods = map[types.UID]string{}
+}
+
+// Release stops counting a Pod that was not remediated after all (eg: deferred or failed)
+func (f *InFlight) Release(uid types.UID) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key, ok := f.pods[uid]
+	if !ok {
+		return
+	}
+	delete(f.pods, uid)
+	f.counts[key]--
+}
+
+// admit reserves a Pod of the workload if the other in-flight Pods and this one leave at least minReady ready replicas
+// It returns the number of ready replicas that would be left
+func (f *InFlight) admit(key string, uid types.UID, ready, minReady int) (int, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.pods[uid]; ok {
+		return ready - f.counts[key], true
+	}
+	left := ready - f.counts[key] - 1
+	if left < minReady {
+		return left, false
+	}
+	f.pods[uid] = key
+	f.counts[key]++
+	return left, true
+}
+
+// ValidateMinAvailable returns error if minAvailable is not a non-negative number or a percentage between 0% and 100%
+func ValidateMinAvailable(minAvailable intstr.IntOrString) error {
+	if minAvailable.Type == intstr.String {
+		s := minAvailable.StrVal
+		if !strings.HasSuffix(s, "%") {
+			return fmt.Errorf("invalid min available %q: expected a number or a percentage", s)
+		}
+	}
+	v, err := intstr.GetScaledValueFromIntOrPercent(&minAvailable, 100, true)
+	if err != nil {
+		return fmt.Errorf("invalid min available %q: %w", minAvailable.String(), err)
+	}
+	if v < 0 || (minAvailable.Type == intstr.String && v > 100) {
+		return fmt.Errorf("invalid min available %q: must be a non-negative number or a percentage up to 100%%", minAvailable.String())
+	}
+	return nil
+}
+
+// NewMinAvailableCheck returns the workload health guard
+// A ready Pod passes when its Deployment, StatefulSet or ReplicaSet keeps at least minAvailable ready replicas
+// (a number, or a percentage of spec.replicas rounded up) without it and the other Pods in flight
+// Pods that are not ready and Pods of other owners always pass, since remediating them does not reduce availability
+func NewMinAvailableCheck(minAvailable intstr.IntOrString, inFlight *InFlight) (Check, error) {
+	err := ValidateMinAvailable(minAvailable)
+	if err != nil {
+		return nil, err
+	}
+	check := NewCheck(CheckMinAvailable, func(ctx context.Context, clientSet kubernetes.Interface, pod *PodDetails) error {
+		if !pod.Ready {
+			return nil
+		}
+		w, err := guardedWorkload(ctx, clientSet, pod)
+		if err != nil || w == nil {
+			return err
+		}
+		minReady, err := intstr.GetScaledValueFromIntOrPercent(&minAvailable, w.replicas, true)
+		if err != nil {
+			return err
+		}
+		key := pod.PodNamespace + "/" + w.String()
+		left, ok := inFlight.admit(key, pod.UID, w.ready, minReady)
+		if !ok {
+			return fmt.Errorf("%w: %s would have %d ready of %d replicas, minimum %d", ErrBelowMinAvailable, w, left, w.replicas, minReady)
+		}
+		return nil
+	})
+	return WithPermissions(check,
+		Permission{Verb: "get", Group: "apps", Resource: "statefulsets"},
+		Permission{Verb: "get", Group: "apps", Resource: "replicasets"},
+		Permission{Verb: "get", Group: "apps", Resource: "deployments"},
+	), nil
+}
+
+// guardedWorkload returns the Deployment, StatefulSet or ReplicaSet (without a Deployment) that owns the Pod,
+// or nil if it has another owner
+func guardedWorkload(ctx context.Context, clientSet kubernetes.Interface, pod *PodDetails) (*workload, error) {
+	ref := controllerOf(pod.OwnerReferences)
+	if ref == nil {
+		return nil, nil
+	}
+	apps := clientSet.AppsV1()
+	switch ref.Kind {
+	case "StatefulSet":
+		sts, err := apps.StatefulSets(pod.PodNamespace).Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("Could not get owner StatefulSet %s/%s: %w", pod.PodNamespace, ref.Name, err)
+		}
+		return &workload{Owner{"StatefulSet", sts.Name}, desiredReplicas(sts.Spec.Replicas), int(sts.Status.ReadyReplicas)}, nil
+	case "ReplicaSet":
+		rs, err := apps.ReplicaSets(pod.PodNamespace).Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("Could not get owner ReplicaSet %s/%s: %w", pod.PodNamespace, ref.Name, err)
+		}
+		deploy := controllerOf(rs.OwnerReferences)
+		if deploy == nil || deploy.Kind != "Deployment" {
+			return &workload{Owner{"ReplicaSet", rs.Name}, desiredReplicas(rs.Spec.Replicas), int(rs.Status.ReadyReplicas)}, nil
+		}
+		d, err := apps.Deployments(pod.PodNamespace).Get(ctx, deploy.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("Could not get owner Deployment %s/%s: %w", pod.PodNamespace, deploy.Name, err)
+		}
+		return &workload{Owner{"Deployment", d.Name}, desiredReplicas(d.Spec.Replicas), int(d.Status.ReadyReplicas)}, nil
+	}
+	return nil, nil
+}
+
+// desiredReplicas returns the desired number of replicas, 1 when it is not set
+func desiredReplicas(n *int32) int {
+	if n == nil {
+		return 1
+	}
+	return int(*n)
+}
@@ -0,0 +1,164 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	types "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestMinAvailableCheck(t *testing.T) {
+	replicas := func(n int32) *int32 { return &n }
+	controller := true
+	ref := func(kind, name string) []metav1.OwnerReference {
+		return []metav1.OwnerReference{{Kind: kind, Name: name, Controller: &controller}}
+	}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec:       appsv1.DeploymentSpec{Replicas: replicas(4)},
+		Status:     appsv1.DeploymentStatus{ReadyReplicas: 4},
+	}
+	replicaSet := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-abc", Namespace: "default", OwnerReferences: ref("Deployment", "web")},
+		Spec:       appsv1.ReplicaSetSpec{Replicas: replicas(4)},
+		Status:     appsv1.ReplicaSetStatus{ReadyReplicas: 4},
+	}
+	bareReplicaSet := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "bare", Namespace: "default"},
+		Spec:       appsv1.ReplicaSetSpec{Replicas: replicas(2)},
+		Status:     appsv1.ReplicaSetStatus{ReadyReplicas: 2},
+	}
+	statefulSet := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+		Spec:       appsv1.StatefulSetSpec{Replicas: replicas(3)},
+		Status:     appsv1.StatefulSetStatus{ReadyReplicas: 2},
+	}
+
+	tests := map[string]struct {
+		mockedObjects []runtime.Object
+		minAvailable  intstr.IntOrString
+		ownerRefs     []metav1.OwnerReference
+		notReady      bool
+		// expectedPassed holds the outcome of checking one ready Pod after the other
+		expectedPassed []bool
+		expectError    bool
+	}{
+		"Deployment keeps an absolute minimum": {
+			mockedObjects:  []runtime.Object{deployment, replicaSet},
+			minAvailable:   intstr.FromInt(2),
+			ownerRefs:      ref("ReplicaSet", "web-abc"),
+			expectedPassed: []bool{true, true, false, false},
+		},
+		"Deployment keeps a percentage rounded up": {
+			mockedObjects:  []runtime.Object{deployment, replicaSet},
+			minAvailable:   intstr.FromString("60%"),
+			ownerRefs:      ref("ReplicaSet", "web-abc"),
+			expectedPassed: []bool{true, false},
+		},
+		"ReplicaSet without Deployment": {
+			mockedObjects:  []runtime.Object{bareReplicaSet},
+			minAvailable:   intstr.FromInt(1),
+			ownerRefs:      ref("ReplicaSet", "bare"),
+			expectedPassed: []bool{true, false},
+		},
+		"StatefulSet already below spec": {
+			mockedObjects:  []runtime.Object{statefulSet},
+			minAvailable:   intstr.FromString("100%"),
+			ownerRefs:      ref("StatefulSet", "db"),
+			expectedPassed: []bool{false},
+		},
+		"Not ready Pods do not reduce availability": {
+			mockedObjects:  []runtime.Object{statefulSet},
+			minAvailable:   intstr.FromString("100%"),
+			ownerRefs:      ref("StatefulSet", "db"),
+			notReady:       true,
+			expectedPassed: []bool{true, true, true},
+		},
+		"Other owners are not guarded": {
+			minAvailable:   intstr.FromInt(10),
+			ownerRefs:      ref("DaemonSet", "agent"),
+			expectedPassed: []bool{true, true},
+		},
+		"Missing owner": {
+			minAvailable: intstr.FromInt(1),
+			ownerRefs:    ref("StatefulSet", "db"),
+			expectError:  true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			clientSet := fake.NewSimpleClientset(tc.mockedObjects...)
+			check, err := NewMinAvailableCheck(tc.minAvailable, NewInFlight())
+			require.NoError(t, err)
+			assert.Equal(t, CheckMinAvailable, check.Name())
+
+			if tc.expectError {
+				pod := &PodDetails{UID: "uid", PodName: "pod", PodNamespace: "default", Ready: true, OwnerReferences: tc.ownerRefs}
+				assert.Error(t, check.Check(context.TODO(), clientSet, pod))
+				return
+			}
+			for i, passed := range tc.expectedPassed {
+				pod := &PodDetails{
+					UID:             types.UID(fmt.Sprintf("uid-%d", i)),
+					PodName:         fmt.Sprintf("pod-%d", i),
+					PodNamespace:    "default",
+					Ready:           !tc.notReady,
+					OwnerReferences: tc.ownerRefs,
+				}
+				err := check.Check(context.TODO(), clientSet, pod)
+				if passed {
+					assert.NoError(t, err, pod.PodName)
+				} else {
+					assert.ErrorIs(t, err, ErrBelowMinAvailable, pod.PodName)
+				}
+			}
+		})
+	}
+}
+
+func TestInFlight(t *testing.T) {
+	f := NewInFlight()
+	left, ok := f.admit("default/Deployment/web", "a", 2, 1)
+	assert.True(t, ok)
+	assert.Equal(t, 1, left)
+
+	// the same Pod matched by another rule is not counted twice
+	_, ok = f.admit("default/Deployment/web", "a", 2, 1)
+	assert.True(t, ok)
+	_, ok = f.admit("default/Deployment/web", "b", 2, 1)
+	assert.False(t, ok)
+
+	// a Pod that was not remediated after all frees its slot
+	f.Release("a")
+	_, ok = f.admit("default/Deployment/web", "b", 2, 1)
+	assert.True(t, ok)
+
+	f.Reset()
+	_, ok = f.admit("default/Deployment/web", "c", 2, 1)
+	assert.True(t, ok)
+}
+
+func TestValidateMinAvailable(t *testing.T) {
+	for value, valid := range map[string]bool{
+		"0":    true,
+		"3":    true,
+		"50%":  true,
+		"100%": true,
+		"-1":   false,
+		"150%": false,
+		"half": false,
+		"5%%":  false,
+	} {
+		err := ValidateMinAvailable(intstr.Parse(value))
+		assert.Equal(t, valid, err == nil, value)
+	}
+}
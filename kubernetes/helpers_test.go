@@ -2,7 +2,7 @@ package kubernetes
 
 import (
 	"fmt"
-	"math/rand"
+	"sync/atomic"
 	"time"
 
 	v1 "k8s.io/api/core/v1"
@@ -10,6 +10,12 @@ import (
 	types "k8s.io/apimachinery/pkg/types"
 )
 
+// testNow is the time of the fake clocks and of the objects made by the helpers
+var testNow = time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+
+// eventSeq makes Event names unique
+var eventSeq atomic.Int64
+
 func makePod(name, namespace string, rv int, phase v1.PodPhase, UID types.UID) *v1.Pod {
 	return &v1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
@@ -17,7 +23,7 @@ func makePod(name, namespace string, rv int, phase v1.PodPhase, UID types.UID) *
 			Name:              name,
 			Namespace:         namespace,
 			ResourceVersion:   fmt.Sprintf("%d", rv),
-			CreationTimestamp: metav1.Time{Time: time.Now()},
+			CreationTimestamp: metav1.Time{Time: testNow},
 			DeletionTimestamp: nil,
 		},
 		Status: v1.PodStatus{
@@ -26,15 +32,31 @@ func makePod(name, namespace string, rv int, phase v1.PodPhase, UID types.UID) *
 	}
 }
 
+func makeLabeledPod(pod *v1.Pod, labels map[string]string) *v1.Pod {
+	pod.ObjectMeta.Labels = labels
+	return pod
+}
+
+func makeNamespace(name string, labels map[string]string) *v1.Namespace {
+	return &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: labels,
+		},
+	}
+}
+
 func makeEvent(name, namespace, eventReason, eventMessage, eventType string,
 	rv int, UID types.UID) *v1.Event {
-	eventTime := metav1.Now()
-	rand.Seed(time.Now().UnixNano())
+	eventTime := metav1.NewTime(testNow)
+	eventName := fmt.Sprintf("%v.%d", name, eventSeq.Add(1))
 
 	return &v1.Event{
 		ObjectMeta: metav1.ObjectMeta{
-			Namespace: namespace,
-			Name:      fmt.Sprintf("%v.%d", name, rand.Intn(10000)),
+			Namespace:       namespace,
+			Name:            eventName,
+			UID:             types.UID(eventName + "-uid"),
+			ResourceVersion: "1",
 		},
 		Reason:  eventReason,
 		Message: eventMessage,
@@ -57,3 +79,9 @@ func makeEvent(name, namespace, eventReason, eventMessage, eventType string,
 		Type:           eventType, // v1.EventTypeNormal, v1.EventTypeWarning
 	}
 }
+
+// seenAt sets the last time the Event was seen
+func seenAt(event *v1.Event, lastSeen time.Time) *v1.Event {
+	event.LastTimestamp = metav1.NewTime(lastSeen)
+	return event
+}
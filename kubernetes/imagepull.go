@@ -0,0 +1,247 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+)
+
+// CheckImagePulled is the name of the check added to the pipeline of image-pull rules
+const CheckImagePulled = "image-pulled"
+
+// Event reasons of image pulls reported by the kubelet
+const (
+	ReasonPulled       = "Pulled"
+	ReasonBackOff      = "BackOff"
+	ReasonFailed       = "Failed"
+	ReasonErrImagePull = "ErrImagePull"
+)
+
+// imagePullWaiting holds the waiting reasons of containers whose image cannot be pulled
+var imagePullWaiting = map[string]bool{"ErrImagePull": true, "ImagePullBackOff": true, "InvalidImageName": true}
+
+// imageRef matches the quoted image of kubelet Events
+// (eg: Back-off pulling image "nginx:1.25", Successfully pulled image "nginx:1.25" in 2s, Container image "nginx:1.25" already present on machine)
+var imageRef = regexp.MustCompile(`image "([^"]+)"`)
+
+// ImageFromMessage returns the image quoted in the message of a kubelet Event, or "" if there is none
+func ImageFromMessage(message string) string {
+	m := imageRef.FindStringSubmatch(message)
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
+// ImageGroup is an image that Pods failed to pull during a cycle
+// PulledOn is the node a Pulled Event reported the image on, "" when the image was never pulled
+type ImageGroup struct {
+	Image    string
+	Pods     []string
+	PulledOn string
+}
+
+// ImagePulls groups the Pods failing to pull an image by image, and remembers the images pulled successfully on some node
+// The Pulled Events of namespaces are listed once per cycle, by the first image-pulled check, and forgotten by Reset
+type ImagePulls struct {
+	namespaces []string
+	pageSize   int64
+
+	mu sync.Mutex
+	// pulled holds the Pulled Events of the current cycle
+	pulled *pulledImages
+	// pods maps images to the Pods (namespace/name) failing to pull them
+	pods map[string]map[string]bool
+}
+
+// pulledImages maps the images of the Pulled Events listed during a cycle to the node they were pulled on
+// The listing runs once, outside of the ImagePulls lock; its outcome is read under the lock
+type pulledImages struct {
+	once  sync.Once
+	nodes map[string]string
+	err   error
+}
+
+// NewImagePulls returns an empty ImagePulls that looks for Pulled Events in namespaces ("" for all namespaces)
+// Events are listed in pages of pageSize (DefaultPageSize when zero)
+func NewImagePulls(namespaces []string, pageSize int64) *ImagePulls {
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	return &ImagePulls{namespaces: namespaces, pageSize: pageSize, pulled: &pulledImages{}, pods: map[string]map[string]bool{}}
+}
+
+// Reset forgets the Pulled Events and the Pods of the previous cycle
+func (p *ImagePulls) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pulled = &pulledImages{}
+	p.pods = map[string]map[string]bool{}
+}
+
+// Groups returns the images Pods failed to pull during the cycle, sorted by image
+func (p *ImagePulls) Groups() []ImageGroup {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	groups := make([]ImageGroup, 0, len(p.pods))
+	for image, pods := range p.pods {
+		g := ImageGroup{Image: image, PulledOn: p.pulled.nodes[image]}
+		for pod := range pods {
+			g.Pods = append(g.Pods, pod)
+		}
+		sort.Strings(g.Pods)
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Image < groups[j].Image })
+	return groups
+}
+
+// pulledOn adds the Pod to the group of image and returns the node the image was pulled on, "" if it never was
+// The first call of a cycle lists the Pulled Events while the others wait for it; a listing error fails every call of the cycle
+func (p *ImagePulls) pulledOn(ctx context.Context, clientSet kubernetes.Interface, pod *PodDetails, image string) (string, error) {
+	p.mu.Lock()
+	if p.pods[image] == nil {
+		p.pods[image] = map[string]bool{}
+	}
+	p.pods[image][pod.PodNamespace+"/"+pod.PodName] = true
+	pulled := p.pulled
+	p.mu.Unlock()
+
+	pulled.once.Do(func() {
+		nodes, err := listPulled(ctx, clientSet, p.namespaces, p.pageSize)
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		pulled.nodes, pulled.err = nodes, err
+	})
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return pulled.nodes[image], pulled.err
+}
+
+// listPulled returns the images of the Pulled Events of namespaces and the node they were pulled on
+func listPulled(ctx context.Context, clientSet kubernetes.Interface, namespaces []string, pageSize int64) (map[string]string, error) {
+	pulled := map[string]string{}
+	opts := listOptions(pageSize)
+	opts.FieldSelector = fields.AndSelectors(
+		fields.OneTermEqualSelector("involvedObject.kind", "Pod"),
+		fields.OneTermEqualSelector("reason", ReasonPulled),
+	).String()
+	for _, namespace := range namespaces {
+		opts := opts
+		for {
+			eventList, err := clientSet.CoreV1().Events(namespace).List(ctx, opts)
+			if err != nil {
+				return nil, fmt.Errorf("Could not get Pulled Events in namespace %q: %w", namespace, forbidden(err))
+			}
+			for _, event := range eventList.Items {
+				image := ImageFromMessage(event.Message)
+				if event.Reason != ReasonPulled || image == "" {
+					continue
+				}
+				pulled[image] = eventNode(&event)
+			}
+			if eventList.Continue == "" {
+				break
+			}
+			nextPage(&opts, eventList.Continue)
+		}
+	}
+	return pulled, nil
+}
+
+// eventNode returns the node that reported a kubelet Event, "unknown" when the Event does not say
+func eventNode(event *v1.Event) string {
+	if event.Source.Host != "" {
+		return event.Source.Host
+	}
+	if event.ReportingInstance != "" {
+		return event.ReportingInstance
+	}
+	return "unknown"
+}
+
+// failingImages returns the images the containers of the Pod are waiting to pull
+// When the container statuses do not tell (eg: they were not updated yet), the images are parsed from the image pull Events of the Pod
+func failingImages(ctx context.Context, clientSet kubernetes.Interface, pod *PodDetails, pageSize int64) ([]string, error) {
+	var images []string
+	seen := map[string]bool{}
+	add := func(image string) {
+		if image != "" && !seen[image] {
+			seen[image] = true
+			images = append(images, image)
+		}
+	}
+	for _, cs := range pod.ContainerStatuses {
+		if cs.State.Waiting != nil && imagePullWaiting[cs.State.Waiting.Reason] {
+			add(cs.Image)
+		}
+	}
+	if len(images) > 0 {
+		return images, nil
+	}
+
+	opts := listOptions(pageSize)
+	opts.FieldSelector = fields.AndSelectors(
+		fields.OneTermEqualSelector("involvedObject.kind", "Pod"),
+		fields.OneTermEqualSelector("involvedObject.name", pod.PodName),
+	).String()
+	for {
+		eventList, err := clientSet.CoreV1().Events(pod.PodNamespace).List(ctx, opts)
+		if err != nil {
+			return nil, fmt.Errorf("Could not get Events of Pod %s/%s: %w", pod.PodNamespace, pod.PodName, forbidden(err))
+		}
+		for _, event := range eventList.Items {
+			if event.InvolvedObject.UID != pod.UID || event.Type != v1.EventTypeWarning {
+				continue
+			}
+			switch event.Reason {
+			case ReasonBackOff, ReasonFailed, ReasonErrImagePull:
+				add(ImageFromMessage(event.Message))
+			}
+		}
+		if eventList.Continue == "" {
+			break
+		}
+		nextPage(&opts, eventList.Continue)
+	}
+	return images, nil
+}
+
+// NewImagePulledCheck returns the check of image-pull rules
+// A Pod passes when every image its containers fail to pull has been pulled successfully on some node, as reported by
+// a Pulled Event: the image exists and the failure is transient (eg: registry outage, expired pull secret since renewed)
+// Otherwise the error wraps ErrImageMissing, since restarting the Pod would fail to pull the image again
+func NewImagePulledCheck(pulls *ImagePulls) Check {
+	check := NewCheck(CheckImagePulled, func(ctx context.Context, clientSet kubernetes.Interface, pod *PodDetails) error {
+		images, err := failingImages(ctx, clientSet, pod, pulls.pageSize)
+		if err != nil {
+			return err
+		}
+		if len(images) == 0 {
+			return fmt.Errorf("Could not find the image Pod %s/%s fails to pull", pod.PodNamespace, pod.PodName)
+		}
+		var missing []string
+		for _, image := range images {
+			node, err := pulls.pulledOn(ctx, clientSet, pod, image)
+			if err != nil {
+				return err
+			}
+			if node == "" {
+				missing = append(missing, image)
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("%w: %s has not been pulled on any node", ErrImageMissing, strings.Join(missing, ", "))
+		}
+		return nil
+	})
+	return WithPermissions(check, Permission{Verb: "list", Resource: "events"})
+}
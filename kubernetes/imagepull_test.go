@@ -0,0 +1,240 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	types "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestImageFromMessage(t *testing.T) {
+	tests := map[string]struct {
+		message       string
+		expectedImage string
+	}{
+		"Back-off":        {`Back-off pulling image "nginx:1.25"`, "nginx:1.25"},
+		"Failed to pull":  {`Failed to pull image "registry.example.com/team/app@sha256:abc": rpc error: code = NotFound`, "registry.example.com/team/app@sha256:abc"},
+		"Pulled":          {`Successfully pulled image "nginx:1.25" in 2.1s (2.1s including waiting)`, "nginx:1.25"},
+		"Already present": {`Container image "nginx:1.25" already present on machine`, "nginx:1.25"},
+		"No image":        {"Error: ErrImagePull", ""},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedImage, ImageFromMessage(tc.message))
+		})
+	}
+}
+
+func TestImagePulledCheck(t *testing.T) {
+	waiting := func(image, reason string) []v1.ContainerStatus {
+		return []v1.ContainerStatus{{Name: "app", Image: image, State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: reason}}}}
+	}
+	pulled := func(namespace, image string) *v1.Event {
+		return makeEvent("other", namespace, "Pulled", `Successfully pulled image "`+image+`" in 1s`, v1.EventTypeNormal, 1, "other-uid")
+	}
+
+	tests := map[string]struct {
+		mockedObjects     []runtime.Object
+		namespaces        []string
+		containerStatuses []v1.ContainerStatus
+		expectedNode      string
+		expectMissing     bool
+		expectError       bool
+	}{
+		"Image pulled on another node": {
+			mockedObjects:     []runtime.Object{pulled("default", "nginx:1.25")},
+			namespaces:        []string{metav1.NamespaceAll},
+			containerStatuses: waiting("nginx:1.25", "ImagePullBackOff"),
+			expectedNode:      "kublet.node1",
+		},
+		"Image already present on another node": {
+			mockedObjects: []runtime.Object{
+				makeEvent("other", "default", "Pulled", `Container image "nginx:1.25" already present on machine`, v1.EventTypeNormal, 1, "other-uid"),
+			},
+			namespaces:        []string{metav1.NamespaceAll},
+			containerStatuses: waiting("nginx:1.25", "ErrImagePull"),
+			expectedNode:      "kublet.node1",
+		},
+		"Image never pulled": {
+			mockedObjects:     []runtime.Object{pulled("default", "nginx:1.24")},
+			namespaces:        []string{metav1.NamespaceAll},
+			containerStatuses: waiting("nginx:1.25", "ImagePullBackOff"),
+			expectMissing:     true,
+		},
+		"Image pulled outside the scanned namespaces": {
+			mockedObjects:     []runtime.Object{pulled("other", "nginx:1.25")},
+			namespaces:        []string{"default"},
+			containerStatuses: waiting("nginx:1.25", "ImagePullBackOff"),
+			expectMissing:     true,
+		},
+		"Image parsed from the Pod Events": {
+			mockedObjects: []runtime.Object{
+				pulled("default", "nginx:1.25"),
+				makeEvent("foo", "default", "BackOff", `Back-off pulling image "nginx:1.25"`, v1.EventTypeWarning, 1, "foo-uid"),
+			},
+			namespaces:   []string{metav1.NamespaceAll},
+			expectedNode: "kublet.node1",
+		},
+		"Image missing from the Pod Events": {
+			mockedObjects: []runtime.Object{
+				makeEvent("foo", "default", "Failed", `Failed to pull image "nginx:1.25": not found`, v1.EventTypeWarning, 1, "foo-uid"),
+			},
+			namespaces:    []string{metav1.NamespaceAll},
+			expectMissing: true,
+		},
+		"No image pull failure": {
+			namespaces:        []string{metav1.NamespaceAll},
+			containerStatuses: waiting("nginx:1.25", "CrashLoopBackOff"),
+			expectError:       true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			clientSet := fake.NewSimpleClientset(tc.mockedObjects...)
+			pulls := NewImagePulls(tc.namespaces, 0)
+			pod := &PodDetails{UID: "foo-uid", PodName: "foo", PodNamespace: "default", ContainerStatuses: tc.containerStatuses}
+
+			err := NewImagePulledCheck(pulls).Check(context.Background(), clientSet, pod)
+			switch {
+			case tc.expectMissing:
+				assert.ErrorIs(t, err, ErrImageMissing)
+			case tc.expectError:
+				assert.Error(t, err)
+				assert.NotErrorIs(t, err, ErrImageMissing)
+				return
+			default:
+				require.NoError(t, err)
+			}
+
+			groups := pulls.Groups()
+			require.Len(t, groups, 1)
+			assert.Equal(t, "nginx:1.25", groups[0].Image)
+			assert.Equal(t, []string{"default/foo"}, groups[0].Pods)
+			assert.Equal(t, tc.expectedNode, groups[0].PulledOn)
+		})
+	}
+}
+
+func TestImagePullsListsOncePerCycle(t *testing.T) {
+	clientSet := fake.NewSimpleClientset(
+		makeEvent("other", "default", "Pulled", `Successfully pulled image "nginx:1.25" in 1s`, v1.EventTypeNormal, 1, "other-uid"),
+	)
+	pulls := NewImagePulls([]string{metav1.NamespaceAll}, 0)
+	check := NewImagePulledCheck(pulls)
+	listPulled := func() int {
+		n := 0
+		for _, action := range clientSet.Actions() {
+			if action.Matches("list", "events") {
+				n++
+			}
+		}
+		return n
+	}
+
+	for _, name := range []string{"foo", "bar"} {
+		pod := &PodDetails{UID: "uid", PodName: name, PodNamespace: "default", ContainerStatuses: []v1.ContainerStatus{
+			{Name: "app", Image: "nginx:1.25", State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "ImagePullBackOff"}}},
+		}}
+		require.NoError(t, check.Check(context.Background(), clientSet, pod))
+	}
+	assert.Equal(t, 1, listPulled())
+	assert.Equal(t, []ImageGroup{{Image: "nginx:1.25", Pods: []string{"default/bar", "default/foo"}, PulledOn: "kublet.node1"}}, pulls.Groups())
+
+	pulls.Reset()
+	assert.Empty(t, pulls.Groups())
+	pod := &PodDetails{UID: "uid", PodName: "foo", PodNamespace: "default", ContainerStatuses: []v1.ContainerStatus{
+		{Name: "app", Image: "nginx:1.25", State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "ImagePullBackOff"}}},
+	}}
+	require.NoError(t, check.Check(context.Background(), clientSet, pod))
+	assert.Equal(t, 2, listPulled())
+}
+
+func TestImagePullsListsOutsideTheLock(t *testing.T) {
+	clientSet := fake.NewSimpleClientset(
+		makeEvent("other", "default", "Pulled", `Successfully pulled image "nginx:1.25" in 1s`, v1.EventTypeNormal, 1, "other-uid"),
+	)
+	listing, release := make(chan struct{}), make(chan struct{})
+	clientSet.PrependReactor("list", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		close(listing)
+		<-release
+		return false, nil, nil
+	})
+	pulls := NewImagePulls([]string{metav1.NamespaceAll}, 0)
+	pod := func(name string) *PodDetails {
+		return &PodDetails{UID: types.UID(name), PodName: name, PodNamespace: "default", ContainerStatuses: []v1.ContainerStatus{
+			{Name: "app", Image: "nginx:1.25", State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "ImagePullBackOff"}}},
+		}}
+	}
+
+	errs := make(chan error, 2)
+	go func() {
+		errs <- NewImagePulledCheck(pulls).Check(context.Background(), clientSet, pod("foo"))
+	}()
+	<-listing
+
+	// other workers group their Pods while the Pulled Events are listed, then wait for the listing
+	go func() {
+		errs <- NewImagePulledCheck(pulls).Check(context.Background(), clientSet, pod("bar"))
+	}()
+	assert.Eventually(t, func() bool {
+		return len(pulls.Groups()) == 1 && len(pulls.Groups()[0].Pods) == 2
+	}, time.Second, time.Millisecond)
+	close(release)
+
+	require.NoError(t, <-errs)
+	require.NoError(t, <-errs)
+	assert.Equal(t, []ImageGroup{{Image: "nginx:1.25", Pods: []string{"default/bar", "default/foo"}, PulledOn: "kublet.node1"}}, pulls.Groups())
+}
+
+func TestImagePulledCheckPageSize(t *testing.T) {
+	pages := map[string]v1.EventList{
+		// Pulled Events
+		"":        {ListMeta: metav1.ListMeta{Continue: "pulled2"}},
+		"pulled2": {Items: []v1.Event{*makeEvent("other", "default", "Pulled", `Successfully pulled image "nginx:1.25" in 1s`, v1.EventTypeNormal, 1, "other-uid")}},
+		// Events of the Pod
+		"pod": {Items: []v1.Event{*makeEvent("foo", "default", "BackOff", `Back-off pulling image "nginx:1.25"`, v1.EventTypeWarning, 1, "foo-uid")}},
+	}
+
+	// the fake clientset drops Limit and Continue, so serve the Events API over HTTP
+	var requests []url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		requests = append(requests, query)
+		page := query.Get("continue")
+		if strings.Contains(query.Get("fieldSelector"), "involvedObject.name=foo") {
+			page = "pod"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pages[page])
+	}))
+	defer server.Close()
+	clientSet, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	require.NoError(t, err)
+
+	pulls := NewImagePulls([]string{"default"}, 1)
+	pod := &PodDetails{UID: "foo-uid", PodName: "foo", PodNamespace: "default"}
+	require.NoError(t, NewImagePulledCheck(pulls).Check(context.Background(), clientSet, pod))
+
+	require.Len(t, requests, 3)
+	for _, query := range requests {
+		assert.Equal(t, "1", query.Get("limit"), query.Get("fieldSelector"))
+	}
+	assert.Equal(t, "pulled2", requests[2].Get("continue"))
+}
@@ -4,172 +4,264 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
-	"strings"
+	"log/slog"
+	"os"
+	"sort"
 	"time"
 
+	"github.com/andreistefanciprian/pod-restarter-go/logging"
 	v1 "k8s.io/api/core/v1"
 	e "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	types "k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/utils/clock"
 )
 
 type K8sClient interface {
-	DeletePod(ctx context.Context, pod, namespace string) error
-	GenerateToBeDeletedPodList(ctx context.Context, namespace, eventReason, errorMessage string, counter, pollingInterval int) (map[string]string, error)
-	PodChecks(ctx context.Context, podName, podNamespace string) error
+	DeletePod(ctx context.Context, pod, namespace string, policy DeletePolicy) error
+	GenerateToBeDeletedPodList(ctx context.Context, filter PodFilter, eventReason, errorMessage string, cursor *EventCursor) (map[string]string, error)
+	PodChecks(ctx context.Context, podName, podNamespace string, checks []Check) (*Verdict, error)
+	PodEvents(ctx context.Context, pod, namespace string) ([]PodEvent, error)
+	MatchesFilter(ctx context.Context, filter PodFilter, pod *PodDetails) (bool, error)
+	OwnerChain(ctx context.Context, pod *PodDetails) ([]Owner, error)
+	Remediate(ctx context.Context, action Action, pod *PodDetails, dryRun bool) (*Result, error)
+	Paused(ctx context.Context, namespace, name string) (bool, error)
+	ReviewAccess(ctx context.Context, permissions []Permission) ([]AccessReview, error)
+	PageSize() int64
 }
 
+// DefaultPageSize is the number of items requested per page when listing Events and Pods
+const DefaultPageSize int64 = 500
+
 // NewK8sClient discover if kubeconfig creds are inside a Pod or outside the cluster and return a clientSet
-func NewK8sClient(kubeconfig string) (*kubeClient, error) {
+// The client is meant to be built once and reused; call Reload to pick up kubeconfig changes
+func NewK8sClient(opts ClientOptions) (*kubeClient, error) {
+	if opts.PageSize <= 0 {
+		opts.PageSize = DefaultPageSize
+	}
+	if opts.Clock == nil {
+		opts.Clock = clock.RealClock{}
+	}
+
+	c := &kubeClient{
+		pageSize: opts.PageSize,
+		opts:     opts,
+		clock:    opts.Clock,
+	}
+	c.limiter = newRateLimiter(opts.QPS, opts.Burst, opts.Clock, opts.Cluster, c.logger())
+
 	// read and parse kubeconfig
-	config, err := rest.InClusterConfig() // creates the in-cluster config
-	if err != nil {
-		config, err = clientcmd.BuildConfigFromFlags("", kubeconfig) // creates the out-cluster config
-		if err != nil {
-			msg := fmt.Sprintf("The kubeconfig cannot be loaded: %v\n", err)
-			return nil, errors.New(msg)
-		}
-		log.Println("Running from OUTSIDE the cluster")
+	// named clusters are always reached through the kubeconfig, even from inside a cluster
+	var config *rest.Config
+	var err error
+	if opts.Cluster == "" {
+		config, err = rest.InClusterConfig() // creates the in-cluster config
+		c.inCluster = err == nil
+	}
+	if c.inCluster {
+		c.logger().Info("Running from INSIDE the cluster")
+		err = c.setClientSet(config)
 	} else {
-		log.Println("Running from INSIDE the cluster")
+		c.logger().Info("Running from OUTSIDE the cluster", "kubeconfig", opts.Kubeconfig, "context", opts.Context)
+		err = c.loadKubeconfig()
 	}
-
-	// create the clientset for in-cluster/out-cluster config
-	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		msg := fmt.Sprintf("The clientset cannot be created: %v\n", err)
-		return nil, errors.New(msg)
+		return nil, err
 	}
+	return c, nil
+}
 
+// NewK8sClientFromClientSet returns a client for an existing clientSet (eg: a fake clientset loaded with recorded objects)
+// Events are aged with clk instead of the wall clock
+func NewK8sClientFromClientSet(clientSet kubernetes.Interface, pageSize int64, clk clock.PassiveClock) *kubeClient {
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
 	return &kubeClient{
-		clientSet: clientset,
-	}, nil
+		clientSet: clientSet,
+		pageSize:  pageSize,
+		clock:     clk,
+	}
+}
+
+// PageSize returns the number of items requested per page when listing Events and Pods
+func (c *kubeClient) PageSize() int64 {
+	return c.pageSize
+}
+
+// logger returns the default logger, labeled with the cluster name in multi-cluster mode
+func (c *kubeClient) logger() *slog.Logger {
+	if c.opts.Cluster == "" {
+		return slog.Default()
+	}
+	return slog.With(logging.KeyCluster, c.opts.Cluster)
+}
+
+// now returns the current time of the client clock, or the wall clock when the client was built without one
+func (c *kubeClient) now() time.Time {
+	if c.clock == nil {
+		return time.Now()
+	}
+	return c.clock.Now()
 }
 
-// listPods returns a list with all the Pods in the Cluster
-func (c *kubeClient) listPods(ctx context.Context, namespace string) (*[]PodDetails, error) {
+// Reload rebuilds the clientset when the kubeconfig file changed since it was last loaded
+// In-cluster credentials are rotated by client-go and never require a reload
+func (c *kubeClient) Reload() error {
+	if c.inCluster {
+		return nil
+	}
+	info, err := os.Stat(c.opts.Kubeconfig)
+	if err != nil {
+		return fmt.Errorf("The kubeconfig cannot be read: %w", err)
+	}
+	if info.ModTime().Equal(c.kubeconfigModTime) {
+		return nil
+	}
+	c.logger().Info("The kubeconfig has changed, reloading client", "kubeconfig", c.opts.Kubeconfig)
+	return c.loadKubeconfig()
+}
+
+// loadKubeconfig builds the clientset from the kubeconfig file and records its modification time
+func (c *kubeClient) loadKubeconfig() error {
+	info, err := os.Stat(c.opts.Kubeconfig)
+	if err != nil {
+		return fmt.Errorf("The kubeconfig cannot be loaded: %w", err)
+	}
+	// creates the out-cluster config
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: c.opts.Kubeconfig},
+		&clientcmd.ConfigOverrides{CurrentContext: c.opts.Context},
+	).ClientConfig()
+	if err != nil {
+		return fmt.Errorf("The kubeconfig cannot be loaded: %w", err)
+	}
+	err = c.setClientSet(config)
+	if err != nil {
+		return err
+	}
+	c.kubeconfigModTime = info.ModTime()
+	return nil
+}
+
+// setClientSet applies ClientOptions to config and creates the clientset for in-cluster/out-cluster config
+// The rate limiter of the client is reused, so a reload does not refill its token bucket
+func (c *kubeClient) setClientSet(config *rest.Config) error {
+	config.RateLimiter = c.limiter
+	config.Wrap(c.limiter.wrap)
+	config.Timeout = c.opts.Timeout
+	if c.opts.UserAgent != "" {
+		config.UserAgent = c.opts.UserAgent
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("The clientset cannot be created: %w", err)
+	}
+	c.clientSet = clientset
+	return nil
+}
+
+// listPods returns a list with all the Pods in the namespace that match the label selector
+// Pods are listed in pages of pageSize
+func (c *kubeClient) listPods(ctx context.Context, namespace, labelSelector string) (*[]PodDetails, error) {
 	api := c.clientSet.CoreV1()
-	var podData PodDetails
 	var podsData []PodDetails
 
-	// list all Pods in Pending state
-	pods, err := api.Pods(namespace).List(
-		ctx,
-		metav1.ListOptions{
-			TypeMeta: metav1.TypeMeta{Kind: "Pod"},
-			// FieldSelector: "status.phase=Pending",
-		},
-	)
-	if err != nil {
-		msg := fmt.Sprintf("Could not get a list of Pods: \n%v", err)
-		return &podsData, errors.New(msg)
-	}
-
-	for _, pod := range pods.Items {
-		podData = PodDetails{
-			UID:               pod.ObjectMeta.UID,
-			PodName:           pod.ObjectMeta.Name,
-			PodNamespace:      pod.ObjectMeta.Namespace,
-			ResourceVersion:   pod.ObjectMeta.ResourceVersion,
-			Phase:             pod.Status.Phase,
-			ContainerStatuses: pod.Status.ContainerStatuses,
-			OwnerReferences:   pod.ObjectMeta.OwnerReferences,
-			CreationTimestamp: pod.ObjectMeta.CreationTimestamp.Time,
-			DeletionTimestamp: pod.ObjectMeta.DeletionTimestamp,
+	opts := listOptions(c.pageSize)
+	opts.LabelSelector = labelSelector
+	for {
+		pods, err := api.Pods(namespace).List(ctx, opts)
+		if err != nil {
+			return &podsData, fmt.Errorf("Could not get a list of Pods in namespace %q: %w", namespace, forbidden(err))
 		}
-		podsData = append(podsData, podData)
+
+		for i := range pods.Items {
+			podsData = append(podsData, newPodDetails(&pods.Items[i]))
+		}
+
+		if pods.Continue == "" {
+			break
+		}
+		nextPage(&opts, pods.Continue)
 	}
-	log.Printf("There is a TOTAL of %d Pods in the cluster\n", len(podsData))
+	c.logger().Debug("Listed Pods", logging.KeyNamespace, namespace, "selector", labelSelector, "pods", len(podsData))
 	return &podsData, nil
 }
 
 // GetEvents returns a list of namespaced Events that match Reason
+// Events are filtered server-side by involvedObject.kind and reason and listed in pages of pageSize
 func (c *kubeClient) GetEvents(ctx context.Context, namespace, eventReason, errorMessage string) ([]PodEvent, error) {
 	api := c.clientSet.CoreV1()
 	var podEvents []PodEvent
 
-	eventList, err := api.Events(namespace).List(
-		ctx,
-		metav1.ListOptions{
-			TypeMeta: metav1.TypeMeta{Kind: "Pod"},
-			// ResourceVersion: "46641835",
-		})
+	opts := listOptions(c.pageSize)
+	opts.FieldSelector = fields.AndSelectors(
+		fields.OneTermEqualSelector("involvedObject.kind", "Pod"),
+		fields.OneTermEqualSelector("reason", eventReason),
+	).String()
+	for {
+		eventList, err := api.Events(namespace).List(ctx, opts)
+		if err != nil {
+			return podEvents, fmt.Errorf("Could not get Events in namespace %q: %w", namespace, forbidden(err))
+		}
 
-	if err != nil {
-		msg := fmt.Sprintf("Could not get Events in namespace: %s\n%s", namespace, err)
-		return podEvents, errors.New(msg)
-	}
-
-	// keep only Events that match event Reason (eg: FailedCreatePodSandBox)
-	// keep only Events that have errorMessage
-	// TO ADD filter out Events older than polling interval
-	for _, item := range eventList.Items {
-		if item.Reason == eventReason && strings.Contains(item.Message, errorMessage) {
-			podEventData := PodEvent{
-				UID:             item.InvolvedObject.UID,
-				PodName:         item.InvolvedObject.Name,
-				PodNamespace:    item.InvolvedObject.Namespace,
-				ResourceVersion: item.InvolvedObject.ResourceVersion,
-				Reason:          item.Reason,
-				EventType:       item.Type,
-				Message:         item.Message,
-				FirstTimestamp:  item.FirstTimestamp.Time,
-				LastTimestamp:   item.LastTimestamp.Time,
+		// keep only Events that match event Reason (eg: FailedCreatePodSandBox)
+		// keep only Events that have errorMessage
+		for i := range eventList.Items {
+			podEvent := newPodEvent(&eventList.Items[i])
+			if podEvent.Matches(eventReason, errorMessage) {
+				podEvents = append(podEvents, podEvent)
 			}
-			podEvents = append(podEvents, podEventData)
 		}
+
+		if eventList.Continue == "" {
+			break
+		}
+		nextPage(&opts, eventList.Continue)
 	}
 	return podEvents, nil
 }
 
-// getPodEvents returns Pod Events
-func (c *kubeClient) getPodEvents(ctx context.Context, pod, namespace string) ([]PodEvent, error) {
-
+// PodEvents returns the Events of a Pod sorted by LastTimestamp
+func (c *kubeClient) PodEvents(ctx context.Context, pod, namespace string) ([]PodEvent, error) {
 	api := c.clientSet.CoreV1()
-
 	var podEvents []PodEvent
-	// get Pod events
-	eventsStruct, err := api.Events(namespace).List(
-		ctx,
-		metav1.ListOptions{
-			FieldSelector: fmt.Sprintf("involvedObject.name=%s", pod),
-			TypeMeta:      metav1.TypeMeta{Kind: "Pod"},
-		})
 
-	if err != nil {
-		msg := fmt.Sprintf("Could not go through Pod's Events: %s/%s\n%s", namespace, pod, err)
-		return podEvents, errors.New(msg)
-	}
-
-	for _, item := range eventsStruct.Items {
-		podEventData := PodEvent{
-			UID:             item.InvolvedObject.UID,
-			PodName:         item.InvolvedObject.Name,
-			PodNamespace:    item.InvolvedObject.Namespace,
-			ResourceVersion: item.InvolvedObject.ResourceVersion,
-			Reason:          item.Reason,
-			EventType:       item.Type,
-			Message:         item.Message,
-			FirstTimestamp:  item.FirstTimestamp.Time,
-			LastTimestamp:   item.LastTimestamp.Time,
+	opts := listOptions(c.pageSize)
+	opts.FieldSelector = fields.AndSelectors(
+		fields.OneTermEqualSelector("involvedObject.kind", "Pod"),
+		fields.OneTermEqualSelector("involvedObject.name", pod),
+	).String()
+	for {
+		eventList, err := api.Events(namespace).List(ctx, opts)
+		if err != nil {
+			return podEvents, fmt.Errorf("Could not get Events of Pod %s/%s: %w", namespace, pod, err)
+		}
+
+		for i := range eventList.Items {
+			if eventList.Items[i].InvolvedObject.Name == pod {
+				podEvents = append(podEvents, newPodEvent(&eventList.Items[i]))
+			}
 		}
-		podEvents = append(podEvents, podEventData)
-	}
 
-	if len(podEvents) == 0 {
-		msg := fmt.Sprintf(
-			"Pod has 0 Events. Probably it does not exist or it does not have any events in the last hour: %s/%s",
-			namespace, pod,
-		)
-		return podEvents, errors.New(msg)
+		if eventList.Continue == "" {
+			break
+		}
+		nextPage(&opts, eventList.Continue)
 	}
+	sort.SliceStable(podEvents, func(i, j int) bool {
+		return podEvents[i].LastTimestamp.Before(podEvents[j].LastTimestamp)
+	})
 	return podEvents, nil
 }
 
 // GetPodDetails returns Pod details
+// The returned error wraps ErrPodNotFound when the Pod does not exist anymore
 func (c *kubeClient) GetPodDetails(ctx context.Context, pod, namespace string) (*PodDetails, error) {
 
 	api := c.clientSet.CoreV1()
@@ -183,70 +275,113 @@ func (c *kubeClient) GetPodDetails(ctx context.Context, pod, namespace string) (
 		metav1.GetOptions{},
 	)
 	if e.IsNotFound(err) {
-		msg := fmt.Sprintf("Pod %s/%s does not exist anymore", namespace, pod)
-		return &podData, errors.New(msg)
-	} else if statusError, isStatus := err.(*e.StatusError); isStatus {
-		msg := fmt.Sprintf("Error getting pod %s/%s: %v",
-			namespace, pod, statusError.ErrStatus.Message)
-		return &podData, errors.New(msg)
+		return &podData, fmt.Errorf("%w: %s/%s: %w", ErrPodNotFound, namespace, pod, err)
 	} else if err != nil {
-		msg := fmt.Sprintf("Pod %s/%s has a problem: %v", namespace, pod, err)
-		return &podData, errors.New(msg)
-	}
-	podData = PodDetails{
-		UID:               item.ObjectMeta.UID,
-		PodName:           item.ObjectMeta.Name,
-		PodNamespace:      item.ObjectMeta.Namespace,
-		ResourceVersion:   item.ObjectMeta.ResourceVersion,
-		Phase:             item.Status.Phase,
-		ContainerStatuses: item.Status.ContainerStatuses,
-		OwnerReferences:   item.ObjectMeta.OwnerReferences,
-		CreationTimestamp: item.ObjectMeta.CreationTimestamp.Time,
-		DeletionTimestamp: item.ObjectMeta.DeletionTimestamp,
+		return &podData, fmt.Errorf("Pod %s/%s has a problem: %w", namespace, pod, err)
 	}
+	podData = newPodDetails(item)
 	return &podData, nil
 }
 
-// DeletePod deletes a Pod
-func (c *kubeClient) DeletePod(ctx context.Context, pod, namespace string) error {
-	api := c.clientSet.CoreV1()
+// DeletePod deletes a Pod using the grace period and propagation policy from DeletePolicy
+func (c *kubeClient) DeletePod(ctx context.Context, pod, namespace string, policy DeletePolicy) error {
+	return deletePod(ctx, c.clientSet, pod, namespace, policy)
+}
+
+// deletePod deletes a Pod with clientSet using the grace period and propagation policy from DeletePolicy
+func deletePod(ctx context.Context, clientSet kubernetes.Interface, pod, namespace string, policy DeletePolicy) error {
+	api := clientSet.CoreV1()
 
 	err := api.Pods(namespace).Delete(
 		ctx,
 		pod,
-		metav1.DeleteOptions{},
+		policy.deleteOptions(),
 	)
 	if err != nil {
 		return err
 	}
-	log.Printf("DELETED Pod %s/%s", namespace, pod)
+	slog.Debug("Deleted Pod",
+		logging.KeyNamespace, namespace,
+		logging.KeyPod, pod,
+		"delete_policy", policy,
+	)
 	return nil
 }
 
 // GenerateToBeDeletedPodList generates a map of Pods that match Event Reason and Error Message
-func (c *kubeClient) GenerateToBeDeletedPodList(ctx context.Context, namespace, eventReason, errorMessage string, counter, pollingInterval int) (map[string]string, error) {
+// Only Pods in the namespaces and with the labels selected by filter are returned
+// Only Events that cursor has not processed yet are considered, and the cursor moves past them; a nil cursor considers every Event
+// The returned error joins the errors of the namespaces that could not be listed, the Pods of the other namespaces are still returned
+func (c *kubeClient) GenerateToBeDeletedPodList(ctx context.Context, filter PodFilter, eventReason, errorMessage string, cursor *EventCursor) (map[string]string, error) {
 
 	var uniquePodList = make(map[string]string)
 
-	// get a list of Events that match Reason
-	eventList, err := c.GetEvents(ctx, namespace, eventReason, errorMessage)
+	scope, err := c.resolveNamespaces(ctx, filter.Namespaces)
 	if err != nil {
 		return uniquePodList, err
 	}
 
-	// Filter out Events that are older than polling interval
-	eventMaxAge := time.Now().Add(-time.Duration(pollingInterval) * time.Second)
-	if counter > 0 {
-		eventList = removeOlderEvents(eventList, eventMaxAge)
+	// get a list of Events that match Reason in the selected namespaces
+	// a namespace that cannot be listed (eg: no Role granted in it) does not stop the others
+	var errs []error
+	failed := make(map[string]bool)
+	events := make(map[string][]PodEvent, len(scope.namespaces))
+	for _, namespace := range scope.namespaces {
+		nsEvents, err := c.GetEvents(ctx, namespace, eventReason, errorMessage)
+		if errors.Is(err, ErrForbidden) && namespace == metav1.NamespaceAll {
+			err = fmt.Errorf("%w (listing all namespaces requires a ClusterRole, Roles require scoped namespaces)", err)
+		}
+		if err != nil {
+			failed[namespace] = true
+			errs = append(errs, err)
+			continue
+		}
+		for _, event := range nsEvents {
+			if scope.match(event.PodNamespace) {
+				events[namespace] = append(events[namespace], event)
+			}
+		}
+	}
+
+	// keep only Events of Pods that match the Pod label selector
+	var selectedPods map[types.UID]bool
+	if filter.PodSelector != "" {
+		var podsFailed map[string]bool
+		selectedPods, podsFailed, err = c.selectPods(ctx, scope, filter.PodSelector)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for namespace := range podsFailed {
+			failed[namespace] = true
+		}
+	}
+
+	// the Events of the namespaces that could not be listed are left to the next iteration
+	var eventList []PodEvent
+	var listed []string
+	for _, namespace := range scope.namespaces {
+		if failed[namespace] {
+			continue
+		}
+		listed = append(listed, namespace)
+		eventList = append(eventList, events[namespace]...)
+	}
+	if selectedPods != nil {
+		eventList = keepSelectedPods(eventList, selectedPods)
+	}
+
+	// keep only Events that have not been processed in a previous iteration
+	if cursor != nil {
+		eventList = cursor.advance(eventList, listed, c.now())
 	}
 
-	log.Printf("There is a total of %d Events with Reason: %s", len(eventList), eventReason) // DEBUG
+	c.logger().Debug("Listed matching Events", "reason", eventReason, "events", len(eventList))
 
 	// generate a unique list of Pods that match Event Reason
 	// we do this because a Pod might have multiple Events with the same Reason
 	uniquePodList = getUniqueListOfPods(eventList)
 
-	log.Printf("There is a total of %d Pods with Reason: %s", len(uniquePodList), eventReason) // DEBUG
+	c.logger().Debug("Listed matching Pods", "reason", eventReason, "pods", len(uniquePodList))
 
-	return uniquePodList, nil
+	return uniquePodList, errors.Join(errs...)
 }
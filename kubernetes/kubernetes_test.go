@@ -2,22 +2,40 @@ package kubernetes
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+	testingclock "k8s.io/utils/clock/testing"
 )
 
 func TestDeletePod(t *testing.T) {
+	gracePeriod := int64(5)
+	propagation := metav1.DeletePropagationBackground
+
 	testCases := []struct {
 		testName      string
 		mockedPods    []runtime.Object
 		podNamespace  string
 		podName       string
+		policy        DeletePolicy
 		expectSuccess bool
 	}{
 		// delete a Pod that exists
@@ -30,6 +48,20 @@ func TestDeletePod(t *testing.T) {
 			podName:       "foo",
 			expectSuccess: true,
 		},
+		// delete a Pod with a custom grace period and propagation policy
+		{
+			testName: "Delete existing Pod with DeletePolicy",
+			mockedPods: []runtime.Object{
+				makePod("foo", "default", 1, corev1.PodRunning, "abc1"),
+			},
+			podNamespace: "default",
+			podName:      "foo",
+			policy: DeletePolicy{
+				GracePeriodSeconds: &gracePeriod,
+				PropagationPolicy:  &propagation,
+			},
+			expectSuccess: true,
+		},
 		// delete a Pod that does not exist
 		{
 			testName:      "Delete Pod that does not exist",
@@ -44,17 +76,28 @@ func TestDeletePod(t *testing.T) {
 		t.Run(test.testName, func(t *testing.T) {
 			var clt kubeClient
 			var ctx = context.TODO()
-			clt.clientSet = fake.NewSimpleClientset(test.mockedPods...)
+			clientSet := fake.NewSimpleClientset(test.mockedPods...)
+			clt.clientSet = clientSet
 			err := clt.DeletePod(
 				ctx,
 				test.podName,
 				test.podNamespace,
+				test.policy,
 			)
 
 			if err != nil && test.expectSuccess {
 				t.Fatalf("Unexpected error deleting existing Pod: %s", err.Error())
 			} else if err == nil && !test.expectSuccess {
-				t.Fatalf("We we're expecting an error for deleting a Pod that does not exist: %s", err.Error())
+				t.Fatalf("We we're expecting an error for deleting a Pod that does not exist")
+			}
+
+			// verify DeletePolicy was sent with the delete request
+			for _, action := range clientSet.Actions() {
+				if deleteAction, ok := action.(k8stesting.DeleteAction); ok {
+					opts := deleteAction.GetDeleteOptions()
+					assert.Equal(t, test.policy.GracePeriodSeconds, opts.GracePeriodSeconds)
+					assert.Equal(t, test.policy.PropagationPolicy, opts.PropagationPolicy)
+				}
 			}
 		})
 	}
@@ -139,6 +182,184 @@ func TestGetEvents(t *testing.T) {
 	}
 }
 
+// writeKubeconfig writes a kubeconfig pointing at server to path
+func writeKubeconfig(t *testing.T, path, server string) {
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: %s
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+current-context: test
+users:
+- name: test
+  user:
+    token: abc
+`, server)
+	require.NoError(t, os.WriteFile(path, []byte(kubeconfig), 0600))
+}
+
+func TestNewK8sClientReload(t *testing.T) {
+	newServer := func(userAgents *[]string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*userAgents = append(*userAgents, r.UserAgent())
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(&corev1.EventList{})
+		}))
+	}
+	var firstRequests, secondRequests []string
+	first := newServer(&firstRequests)
+	defer first.Close()
+	second := newServer(&secondRequests)
+	defer second.Close()
+
+	path := filepath.Join(t.TempDir(), "config")
+	writeKubeconfig(t, path, first.URL)
+
+	clt, err := NewK8sClient(ClientOptions{Kubeconfig: path, UserAgent: "pod-restarter-test", Timeout: time.Second})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultPageSize, clt.pageSize)
+
+	// unchanged kubeconfig keeps the same clientset
+	clientSet := clt.clientSet
+	require.NoError(t, clt.Reload())
+	assert.Same(t, clientSet, clt.clientSet)
+
+	_, err = clt.GetEvents(context.TODO(), "default", "BackOff", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pod-restarter-test"}, firstRequests)
+
+	// changed kubeconfig rebuilds the clientset against the new server
+	writeKubeconfig(t, path, second.URL)
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+	require.NoError(t, clt.Reload())
+
+	_, err = clt.GetEvents(context.TODO(), "default", "BackOff", "")
+	require.NoError(t, err)
+	assert.Len(t, firstRequests, 1)
+	assert.Equal(t, []string{"pod-restarter-test"}, secondRequests)
+}
+
+func TestNewK8sClientContext(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Host)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&corev1.EventList{})
+	}))
+	defer server.Close()
+
+	// the current context points at a server that does not exist
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: prod
+  cluster:
+    server: %s
+- name: dev
+  cluster:
+    server: http://127.0.0.1:1
+contexts:
+- name: prod
+  context:
+    cluster: prod
+    user: test
+- name: dev
+  context:
+    cluster: dev
+    user: test
+current-context: dev
+users:
+- name: test
+  user:
+    token: abc
+`, server.URL)
+	path := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(path, []byte(kubeconfig), 0600))
+
+	clt, err := NewK8sClient(ClientOptions{Cluster: "prod", Kubeconfig: path, Context: "prod", Timeout: time.Second})
+	require.NoError(t, err)
+	_, err = clt.GetEvents(context.TODO(), "default", "BackOff", "")
+	require.NoError(t, err)
+	assert.Len(t, requests, 1)
+
+	_, err = NewK8sClient(ClientOptions{Cluster: "staging", Kubeconfig: path, Context: "staging"})
+	assert.Error(t, err)
+}
+
+func TestGetEventsPagination(t *testing.T) {
+	pages := map[string]*corev1.EventList{
+		"": {
+			ListMeta: metav1.ListMeta{Continue: "page2"},
+			Items: []corev1.Event{
+				*makeEvent("pod_1", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists ....", "Warning", 1, "uid1"),
+			},
+		},
+		"page2": {
+			Items: []corev1.Event{
+				*makeEvent("pod_2", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists ....", "Warning", 1, "uid2"),
+				*makeEvent("pod_3", "default", "FailedCreatePodSandBox", "something else", "Warning", 1, "uid3"),
+			},
+		},
+	}
+
+	// the fake clientset drops Limit and Continue, so serve the Events API over HTTP
+	var requests []url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		requests = append(requests, query)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pages[query.Get("continue")])
+	}))
+	defer server.Close()
+
+	clientSet, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	require.NoError(t, err)
+
+	clt := kubeClient{clientSet: clientSet, pageSize: 1}
+	podEvents, err := clt.GetEvents(context.TODO(), "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists")
+	require.NoError(t, err)
+	assert.Len(t, podEvents, 2)
+
+	// first page is served from the watch cache, next pages follow the continue token
+	require.Len(t, requests, 2)
+	assert.Equal(t, "involvedObject.kind=Pod,reason=FailedCreatePodSandBox", requests[0].Get("fieldSelector"))
+	assert.Equal(t, "1", requests[0].Get("limit"))
+	assert.Equal(t, "0", requests[0].Get("resourceVersion"))
+	assert.Equal(t, "", requests[0].Get("continue"))
+	assert.Equal(t, "", requests[1].Get("resourceVersion"))
+	assert.Equal(t, "page2", requests[1].Get("continue"))
+}
+
+func TestPodEvents(t *testing.T) {
+	older := makeEvent("foo", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", "Warning", 2, "uid1")
+	older.LastTimestamp = metav1.NewTime(testNow.Add(-time.Minute))
+	clientSet := fake.NewSimpleClientset(
+		makeEvent("foo", "default", "BackOff", "Back-off restarting failed container", "Warning", 3, "uid1"),
+		older,
+		makeEvent("bar", "default", "BackOff", "Back-off restarting failed container", "Warning", 1, "uid2"),
+		makeEvent("foo", "test", "BackOff", "Back-off restarting failed container", "Warning", 1, "uid3"),
+	)
+	clt := kubeClient{clientSet: clientSet}
+
+	events, err := clt.PodEvents(context.TODO(), "foo", "default")
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "FailedCreatePodSandBox", events[0].Reason, "Expected Events sorted by LastTimestamp")
+	assert.Equal(t, "BackOff", events[1].Reason)
+	assert.True(t, events[1].Matches("BackOff", "Back-off restarting"))
+	assert.False(t, events[1].Matches("BackOff", "Back-off pulling image"))
+
+	events, err = clt.PodEvents(context.TODO(), "baz", "default")
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
+
 func TestGetPodDetails(t *testing.T) {
 	testCases := []struct {
 		testName      string
@@ -181,6 +402,153 @@ func TestGetPodDetails(t *testing.T) {
 				t.Fatalf("Unexpected error geting existing Pod: %s", err.Error())
 			} else if err == nil && !test.expectSuccess {
 				t.Fatalf("We we're expecting an Error for getting details of a Pod that does not exist!")
+			} else if err != nil {
+				assert.ErrorIs(t, err, ErrPodNotFound)
+			}
+		})
+	}
+}
+
+func TestGenerateToBeDeletedPodListCursor(t *testing.T) {
+	const (
+		reason  = "FailedCreatePodSandBox"
+		message = "container veth name provided (eth0) already exists"
+	)
+	pod1 := seenAt(makeEvent("pod_1", "default", reason, message, "Warning", 1, "uid1"), testNow.Add(-5*time.Second))
+	clientSet := fake.NewSimpleClientset(
+		pod1,
+		seenAt(makeEvent("pod_2", "default", reason, message, "Warning", 1, "uid2"), testNow.Add(-4*time.Minute)),
+		seenAt(makeEvent("pod_3", "default", reason, message, "Warning", 1, "uid3"), testNow.Add(-time.Hour)),
+	)
+	clk := testingclock.NewFakeClock(testNow)
+	clt := NewK8sClientFromClientSet(clientSet, 0, clk)
+	cursor := NewEventCursor(5 * time.Minute)
+
+	testCases := []struct {
+		testName     string
+		step         time.Duration
+		update       func(t *testing.T)
+		expectedPods []string
+	}{
+		{
+			testName:     "First iteration ignores Events older than the lookback window",
+			expectedPods: []string{"pod_1", "pod_2"},
+		},
+		{
+			testName: "Processed Events are not processed again",
+			step:     30 * time.Second,
+		},
+		{
+			testName: "Events seen again are processed again",
+			step:     30 * time.Second,
+			update: func(t *testing.T) {
+				event := seenAt(pod1.DeepCopy(), testNow.Add(time.Minute))
+				event.Count++
+				event.ResourceVersion = "2"
+				_, err := clientSet.CoreV1().Events("default").Update(context.TODO(), event, metav1.UpdateOptions{})
+				require.NoError(t, err)
+			},
+			expectedPods: []string{"pod_1"},
+		},
+		{
+			testName: "New Events are processed whatever their age",
+			step:     30 * time.Second,
+			update: func(t *testing.T) {
+				// eg: an Event that landed while the previous iteration was waiting for Pods to self heal
+				event := seenAt(makeEvent("pod_4", "default", reason, message, "Warning", 1, "uid4"), testNow.Add(-time.Hour))
+				_, err := clientSet.CoreV1().Events("default").Create(context.TODO(), event, metav1.CreateOptions{})
+				require.NoError(t, err)
+			},
+			expectedPods: []string{"pod_4"},
+		},
+		{
+			testName: "No Event is processed twice",
+			step:     time.Hour,
+		},
+	}
+
+	// the test cases step the same clock and cursor one after the other
+	for _, test := range testCases {
+		clk.Step(test.step)
+		if test.update != nil {
+			test.update(t)
+		}
+		uniquePodList, err := clt.GenerateToBeDeletedPodList(context.TODO(), PodFilter{}, reason, message, cursor)
+		require.NoError(t, err, test.testName)
+		var pods []string
+		for pod := range uniquePodList {
+			pods = append(pods, pod)
+		}
+		assert.ElementsMatch(t, test.expectedPods, pods, test.testName)
+	}
+}
+
+func TestGenerateToBeDeletedPodListCursorListingErrors(t *testing.T) {
+	const (
+		reason  = "FailedCreatePodSandBox"
+		message = "container veth name provided (eth0) already exists"
+	)
+	unavailable := apierrors.NewServiceUnavailable("etcd is unavailable")
+
+	testCases := map[string]struct {
+		// failedResource is the resource whose listing fails on the second iteration
+		failedResource string
+		filter         PodFilter
+		// lastSeen is when the Event was last seen, before the first iteration
+		lastSeen time.Duration
+		// createdAfter creates the Event after the first iteration
+		createdAfter bool
+		expectedPods []map[string]string
+	}{
+		"Failed Events listing keeps the processed Events": {
+			failedResource: "events",
+			lastSeen:       -50 * time.Minute,
+			expectedPods:   []map[string]string{{}, {}, {}},
+		},
+		"Failed Pods listing leaves the Events to the next iteration": {
+			failedResource: "pods",
+			filter:         PodFilter{PodSelector: "app=web"},
+			createdAfter:   true,
+			expectedPods:   []map[string]string{{}, {}, {"web": "default"}},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			event := seenAt(makeEvent("web", "default", reason, message, "Warning", 1, "web-uid"), testNow.Add(tc.lastSeen))
+			objects := []runtime.Object{makeLabeledPod(makePod("web", "default", 1, corev1.PodPending, "web-uid"), map[string]string{"app": "web"})}
+			if !tc.createdAfter {
+				objects = append(objects, event)
+			}
+			clientSet := fake.NewSimpleClientset(objects...)
+			fail := false
+			clientSet.PrependReactor("list", tc.failedResource, func(action k8stesting.Action) (bool, runtime.Object, error) {
+				if fail {
+					return true, nil, unavailable
+				}
+				return false, nil, nil
+			})
+			clk := testingclock.NewFakeClock(testNow)
+			clt := NewK8sClientFromClientSet(clientSet, 0, clk)
+			cursor := NewEventCursor(5 * time.Minute)
+
+			for i, expected := range tc.expectedPods {
+				if i == 1 {
+					fail = true
+					if tc.createdAfter {
+						_, err := clientSet.CoreV1().Events("default").Create(context.TODO(), event, metav1.CreateOptions{})
+						require.NoError(t, err)
+					}
+				}
+				pods, err := clt.GenerateToBeDeletedPodList(context.TODO(), tc.filter, reason, message, cursor)
+				if fail {
+					assert.ErrorIs(t, err, unavailable)
+				} else {
+					require.NoError(t, err)
+				}
+				assert.Equal(t, expected, pods, "iteration %d", i+1)
+				fail = false
+				clk.Step(30 * time.Second)
 			}
 		})
 	}
@@ -190,11 +558,10 @@ func TestGenerateToBeDeletedPodList(t *testing.T) {
 	testCases := []struct {
 		testName              string
 		mockedEvents          []runtime.Object
-		eventNamespace        string
+		mockedObjects         []runtime.Object
+		filter                PodFilter
 		eventReason           string
 		eventMessage          string
-		counter               int
-		pollingInterval       int
 		expectedUniquePodList int
 	}{
 		// This test is looking for Events that match Reason and Message in a namespace
@@ -211,7 +578,7 @@ func TestGenerateToBeDeletedPodList(t *testing.T) {
 				makeEvent("pod_3", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists ....", "Warning", 3, "uid3"),
 				makeEvent("pod_4", "test2", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists ....", "Warning", 1, "uid4"),
 			},
-			eventNamespace:        "default",
+			filter:                PodFilter{Namespaces: NamespaceFilter{Include: []string{"default"}}},
 			eventReason:           "FailedCreatePodSandBox",
 			eventMessage:          "container veth name provided (eth0) already exists",
 			expectedUniquePodList: 2,
@@ -230,7 +597,7 @@ func TestGenerateToBeDeletedPodList(t *testing.T) {
 				makeEvent("pod_4", "test2", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists ....", "Warning", 1, "uid4"),
 				makeEvent("pod_4", "test2", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists ....", "Warning", 2, "uid4"),
 			},
-			eventNamespace:        "",
+			filter:                PodFilter{},
 			eventReason:           "FailedCreatePodSandBox",
 			eventMessage:          "container veth name provided (eth0) already exists",
 			expectedUniquePodList: 4,
@@ -240,7 +607,7 @@ func TestGenerateToBeDeletedPodList(t *testing.T) {
 		{
 			testName:              "Get no matching Events from namespace",
 			mockedEvents:          []runtime.Object{},
-			eventNamespace:        "default",
+			filter:                PodFilter{Namespaces: NamespaceFilter{Include: []string{"default"}}},
 			eventReason:           "FailedCreatePodSandBox",
 			eventMessage:          "container veth name provided (eth0) already exists",
 			expectedUniquePodList: 0,
@@ -250,16 +617,74 @@ func TestGenerateToBeDeletedPodList(t *testing.T) {
 		{
 			testName:              "Get no matching Events across all namespaces",
 			mockedEvents:          []runtime.Object{},
-			eventNamespace:        "",
+			filter:                PodFilter{},
 			eventReason:           "FailedCreatePodSandBox",
 			eventMessage:          "container veth name provided (eth0) already exists",
 			expectedUniquePodList: 0,
 		},
+		// This test is looking for Events across all namespaces except the excluded ones
+		{
+			testName: "Get Events that match Reason and Message excluding namespaces",
+			mockedEvents: []runtime.Object{
+				makeEvent("pod_1", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists ....", "Warning", 1, "uid1"),
+				makeEvent("pod_2", "kube-system", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists ....", "Warning", 1, "uid2"),
+				makeEvent("pod_3", "istio-system", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists ....", "Warning", 1, "uid3"),
+			},
+			filter:                PodFilter{Namespaces: NamespaceFilter{Exclude: []string{"kube-system", "istio-*"}}},
+			eventReason:           "FailedCreatePodSandBox",
+			eventMessage:          "container veth name provided (eth0) already exists",
+			expectedUniquePodList: 1,
+		},
+		// This test is looking for Events in namespaces matching a glob pattern
+		{
+			testName: "Get Events that match Reason and Message in namespaces matching glob",
+			mockedEvents: []runtime.Object{
+				makeEvent("pod_1", "team-a", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists ....", "Warning", 1, "uid1"),
+				makeEvent("pod_2", "team-b", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists ....", "Warning", 1, "uid2"),
+				makeEvent("pod_3", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists ....", "Warning", 1, "uid3"),
+			},
+			filter:                PodFilter{Namespaces: NamespaceFilter{Include: []string{"team-*"}}},
+			eventReason:           "FailedCreatePodSandBox",
+			eventMessage:          "container veth name provided (eth0) already exists",
+			expectedUniquePodList: 2,
+		},
+		// This test is looking for Events in namespaces matching a label selector
+		{
+			testName: "Get Events that match Reason and Message in namespaces matching selector",
+			mockedEvents: []runtime.Object{
+				makeEvent("pod_1", "platform", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists ....", "Warning", 1, "uid1"),
+				makeEvent("pod_2", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists ....", "Warning", 1, "uid2"),
+			},
+			mockedObjects: []runtime.Object{
+				makeNamespace("platform", map[string]string{"team": "platform"}),
+				makeNamespace("default", nil),
+			},
+			filter:                PodFilter{Namespaces: NamespaceFilter{Selector: "team=platform"}},
+			eventReason:           "FailedCreatePodSandBox",
+			eventMessage:          "container veth name provided (eth0) already exists",
+			expectedUniquePodList: 1,
+		},
+		// This test is looking for Events of Pods matching a label selector
+		{
+			testName: "Get Events that match Reason and Message for Pods matching selector",
+			mockedEvents: []runtime.Object{
+				makeEvent("pod_1", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists ....", "Warning", 1, "uid1"),
+				makeEvent("pod_2", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists ....", "Warning", 1, "uid2"),
+			},
+			mockedObjects: []runtime.Object{
+				makeLabeledPod(makePod("pod_1", "default", 1, corev1.PodPending, "uid1"), map[string]string{"app": "nginx"}),
+				makePod("pod_2", "default", 1, corev1.PodPending, "uid2"),
+			},
+			filter:                PodFilter{Namespaces: NamespaceFilter{Include: []string{"default"}}, PodSelector: "app=nginx"},
+			eventReason:           "FailedCreatePodSandBox",
+			eventMessage:          "container veth name provided (eth0) already exists",
+			expectedUniquePodList: 1,
+		},
 		// // Test when getting an error while getting Events
 		// {
 		// 	testName:              "Get error while getting Events",
 		// 	mockedEvents:          []runtime.Object{},
-		// 	eventNamespace:        "",
+		// 	filter:                PodFilter{},
 		// 	eventReason:           "FailedCreatePodSandBox",
 		// 	eventMessage:          "container veth name provided (eth0) already exists",
 		// 	expectedUniquePodList: 0,
@@ -270,19 +695,18 @@ func TestGenerateToBeDeletedPodList(t *testing.T) {
 		t.Run(test.testName, func(t *testing.T) {
 			var clt kubeClient
 			var ctx = context.TODO()
-			clt.clientSet = fake.NewSimpleClientset(test.mockedEvents...)
+			clt.clientSet = fake.NewSimpleClientset(append(test.mockedEvents, test.mockedObjects...)...)
 			uniquePodList, err := clt.GenerateToBeDeletedPodList(
 				ctx,
-				test.eventNamespace,
+				test.filter,
 				test.eventReason,
 				test.eventMessage,
-				0,
-				10,
+				nil,
 			)
 
 			if err != nil {
 				assert.NotNil(t, err)
-				assert.Equal(t, fmt.Sprintf("Could not get Events in namespace: %s\n%s", test.eventNamespace, err), err.Error())
+				assert.Contains(t, err.Error(), "Could not get Events in namespace")
 			} else if test.expectedUniquePodList != len(uniquePodList) {
 				require.NoError(t, err)
 				assert.Nil(t, err)
@@ -291,3 +715,32 @@ func TestGenerateToBeDeletedPodList(t *testing.T) {
 		})
 	}
 }
+
+func TestGenerateToBeDeletedPodListForbiddenNamespace(t *testing.T) {
+	const reason, message = "FailedCreatePodSandBox", "container veth name provided (eth0) already exists"
+	clientSet := fake.NewSimpleClientset(
+		makeEvent("pod_1", "team-a", reason, message, "Warning", 1, "uid1"),
+		makeEvent("pod_2", "team-b", reason, message, "Warning", 1, "uid2"),
+		makeEvent("pod_3", "team-c", reason, message, "Warning", 1, "uid3"),
+	)
+	// no Role is granted in team-b
+	clientSet.PrependReactor("list", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetNamespace() == "team-b" || action.GetNamespace() == metav1.NamespaceAll {
+			return true, nil, apierrors.NewForbidden(action.GetResource().GroupResource(), "", errors.New("no Role"))
+		}
+		return false, nil, nil
+	})
+	clt := NewK8sClientFromClientSet(clientSet, 0, testingclock.NewFakeClock(testNow))
+
+	// the namespaces that can be listed are still scanned
+	filter := PodFilter{Namespaces: NamespaceFilter{Include: []string{"team-a", "team-b", "team-c"}, Scoped: true}}
+	pods, err := clt.GenerateToBeDeletedPodList(context.TODO(), filter, reason, message, nil)
+	assert.ErrorIs(t, err, ErrForbidden)
+	assert.ErrorContains(t, err, `namespace "team-b"`)
+	assert.Equal(t, map[string]string{"pod_1": "team-a", "pod_3": "team-c"}, pods)
+
+	// listing all namespaces without a ClusterRole explains how to run with Roles
+	_, err = clt.GenerateToBeDeletedPodList(context.TODO(), PodFilter{}, reason, message, nil)
+	assert.ErrorIs(t, err, ErrForbidden)
+	assert.ErrorContains(t, err, "scoped namespaces")
+}
@@ -0,0 +1,66 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Owner identifies a controller in the ownership chain of a Pod
+type Owner struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+// String returns the owner as Kind/Name
+func (o Owner) String() string {
+	return o.Kind + "/" + o.Name
+}
+
+// OwnerChain returns the controllers of a Pod, from the direct owner up to the top level controller
+// (eg: ReplicaSet/foo-abc then Deployment/foo); ReplicaSets and Jobs are followed to their own controller
+func OwnerChain(ctx context.Context, clientSet kubernetes.Interface, pod *PodDetails) ([]Owner, error) {
+	var chain []Owner
+	ref := controllerOf(pod.OwnerReferences)
+	for ref != nil {
+		chain = append(chain, Owner{Kind: ref.Kind, Name: ref.Name})
+
+		var refs []metav1.OwnerReference
+		switch ref.Kind {
+		case "ReplicaSet":
+			rs, err := clientSet.AppsV1().ReplicaSets(pod.PodNamespace).Get(ctx, ref.Name, metav1.GetOptions{})
+			if err != nil {
+				return chain, fmt.Errorf("Could not get owner ReplicaSet %s/%s: %w", pod.PodNamespace, ref.Name, err)
+			}
+			refs = rs.OwnerReferences
+		case "Job":
+			job, err := clientSet.BatchV1().Jobs(pod.PodNamespace).Get(ctx, ref.Name, metav1.GetOptions{})
+			if err != nil {
+				return chain, fmt.Errorf("Could not get owner Job %s/%s: %w", pod.PodNamespace, ref.Name, err)
+			}
+			refs = job.OwnerReferences
+		}
+		ref = controllerOf(refs)
+	}
+	return chain, nil
+}
+
+// OwnerChain returns the controllers of a Pod, from the direct owner up to the top level controller
+func (c *kubeClient) OwnerChain(ctx context.Context, pod *PodDetails) ([]Owner, error) {
+	return OwnerChain(ctx, c.clientSet, pod)
+}
+
+// controllerOf returns the controller reference, or the first owner reference when there is no controller
+func controllerOf(refs []metav1.OwnerReference) *metav1.OwnerReference {
+	for i := range refs {
+		if refs[i].Controller != nil && *refs[i].Controller {
+			return &refs[i]
+		}
+	}
+	if len(refs) > 0 {
+		return &refs[0]
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestOwnerChain(t *testing.T) {
+	controller := true
+	ref := func(kind, name string) []metav1.OwnerReference {
+		return []metav1.OwnerReference{{Kind: kind, Name: name, Controller: &controller}}
+	}
+
+	tests := map[string]struct {
+		mockedObjects []runtime.Object
+		ownerRefs     []metav1.OwnerReference
+		expectedChain []Owner
+		expectError   bool
+	}{
+		"Bare Pod has no owners": {
+			expectedChain: nil,
+		},
+		"Deployment Pod": {
+			mockedObjects: []runtime.Object{
+				&appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Name: "foo-abc", Namespace: "default", OwnerReferences: ref("Deployment", "foo")}},
+			},
+			ownerRefs:     ref("ReplicaSet", "foo-abc"),
+			expectedChain: []Owner{{Kind: "ReplicaSet", Name: "foo-abc"}, {Kind: "Deployment", Name: "foo"}},
+		},
+		"CronJob Pod": {
+			mockedObjects: []runtime.Object{
+				&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "backup-123", Namespace: "default", OwnerReferences: ref("CronJob", "backup")}},
+			},
+			ownerRefs:     ref("Job", "backup-123"),
+			expectedChain: []Owner{{Kind: "Job", Name: "backup-123"}, {Kind: "CronJob", Name: "backup"}},
+		},
+		"StatefulSet Pod": {
+			ownerRefs:     ref("StatefulSet", "db"),
+			expectedChain: []Owner{{Kind: "StatefulSet", Name: "db"}},
+		},
+		"Missing ReplicaSet": {
+			ownerRefs:   ref("ReplicaSet", "foo-abc"),
+			expectError: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			clientSet := fake.NewSimpleClientset(tc.mockedObjects...)
+			pod := &PodDetails{PodName: "foo", PodNamespace: "default", OwnerReferences: tc.ownerRefs}
+
+			chain, err := OwnerChain(context.TODO(), clientSet, pod)
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedChain, chain)
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	e "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PauseAnnotation pauses all remediations when it is set to "true" on the pause ConfigMap
+// eg: kubectl annotate configmap pod-restarter pod-restarter/paused=true
+const PauseAnnotation = "pod-restarter/paused"
+
+// Paused returns true if the ConfigMap has PauseAnnotation set to true
+// A ConfigMap that does not exist does not pause remediations
+func (c *kubeClient) Paused(ctx context.Context, namespace, name string) (bool, error) {
+	cm, err := c.clientSet.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+	if e.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("Could not get pause ConfigMap %s/%s: %w", namespace, name, err)
+	}
+	value, ok := cm.Annotations[PauseAnnotation]
+	if !ok {
+		return false, nil
+	}
+	paused, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("Invalid %s annotation on ConfigMap %s/%s: %q", PauseAnnotation, namespace, name, value)
+	}
+	return paused, nil
+}
@@ -0,0 +1,55 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestPaused(t *testing.T) {
+	configMap := func(annotations map[string]string) *v1.ConfigMap {
+		return &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "pod-restarter", Namespace: "kube-system", Annotations: annotations}}
+	}
+
+	tests := map[string]struct {
+		mockedObjects  []runtime.Object
+		expectedPaused bool
+		expectError    bool
+	}{
+		"Missing ConfigMap": {},
+		"No annotation": {
+			mockedObjects: []runtime.Object{configMap(nil)},
+		},
+		"Paused": {
+			mockedObjects:  []runtime.Object{configMap(map[string]string{PauseAnnotation: "true"})},
+			expectedPaused: true,
+		},
+		"Resumed": {
+			mockedObjects: []runtime.Object{configMap(map[string]string{PauseAnnotation: "false"})},
+		},
+		"Invalid annotation": {
+			mockedObjects: []runtime.Object{configMap(map[string]string{PauseAnnotation: "yes please"})},
+			expectError:   true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			clientSet := fake.NewSimpleClientset(tc.mockedObjects...)
+			clt := NewK8sClientFromClientSet(clientSet, 0, testingclock.NewFakeClock(testNow))
+			paused, err := clt.Paused(context.TODO(), "kube-system", "pod-restarter")
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedPaused, paused)
+		})
+	}
+}
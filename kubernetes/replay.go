@@ -0,0 +1,75 @@
+package kubernetes
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	yamlutil "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// LoadObjects decodes the objects recorded in a JSON or YAML document (eg: `kubectl get events -A -o json`)
+// Lists (List, EventList and PodList) are flattened and multiple YAML documents are supported
+// Objects of kinds unknown to client-go are ignored
+func LoadObjects(r io.Reader) ([]runtime.Object, error) {
+	decoder := yamlutil.NewYAMLOrJSONDecoder(r, 4096)
+	var objects []runtime.Object
+	for {
+		var raw runtime.RawExtension
+		err := decoder.Decode(&raw)
+		if errors.Is(err, io.EOF) {
+			return objects, nil
+		}
+		if err != nil {
+			return objects, fmt.Errorf("Could not read recorded objects: %w", err)
+		}
+		if len(raw.Raw) == 0 {
+			continue
+		}
+		decoded, err := decodeObjects(raw.Raw)
+		if err != nil {
+			return objects, err
+		}
+		objects = append(objects, decoded...)
+	}
+}
+
+// decodeObjects decodes a JSON object, or the items of a JSON list
+func decodeObjects(data []byte) ([]runtime.Object, error) {
+	obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(data, nil, nil)
+	if runtime.IsNotRegisteredError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Could not decode recorded object: %w", err)
+	}
+
+	switch o := obj.(type) {
+	case *v1.EventList:
+		objects := make([]runtime.Object, 0, len(o.Items))
+		for i := range o.Items {
+			objects = append(objects, &o.Items[i])
+		}
+		return objects, nil
+	case *v1.PodList:
+		objects := make([]runtime.Object, 0, len(o.Items))
+		for i := range o.Items {
+			objects = append(objects, &o.Items[i])
+		}
+		return objects, nil
+	case *v1.List:
+		var objects []runtime.Object
+		for _, item := range o.Items {
+			decoded, err := decodeObjects(item.Raw)
+			if err != nil {
+				return objects, err
+			}
+			objects = append(objects, decoded...)
+		}
+		return objects, nil
+	}
+	return []runtime.Object{obj}, nil
+}
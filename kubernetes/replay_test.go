@@ -0,0 +1,82 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadObjects(t *testing.T) {
+	tests := map[string]struct {
+		content       string
+		expectedKinds []string
+		expectError   bool
+	}{
+		"kubectl get -o json List": {
+			content: `{
+  "apiVersion": "v1",
+  "kind": "List",
+  "items": [
+    {"apiVersion": "v1", "kind": "Event", "metadata": {"name": "foo.1", "namespace": "default"}, "reason": "BackOff",
+     "involvedObject": {"kind": "Pod", "name": "foo", "namespace": "default"}},
+    {"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "foo", "namespace": "default"}},
+    {"apiVersion": "example.com/v1", "kind": "Widget", "metadata": {"name": "foo"}}
+  ]
+}`,
+			expectedKinds: []string{"*v1.Event", "*v1.Pod"},
+		},
+		"EventList": {
+			content: `{"apiVersion": "v1", "kind": "EventList", "items": [
+  {"metadata": {"name": "foo.1", "namespace": "default"}},
+  {"metadata": {"name": "foo.2", "namespace": "default"}}
+]}`,
+			expectedKinds: []string{"*v1.Event", "*v1.Event"},
+		},
+		"Multiple YAML documents": {
+			content: `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: foo
+  namespace: default
+---
+apiVersion: apps/v1
+kind: ReplicaSet
+metadata:
+  name: foo-abc
+  namespace: default
+---
+apiVersion: v1
+kind: PodList
+items:
+  - metadata:
+      name: bar
+      namespace: default
+`,
+			expectedKinds: []string{"*v1.Pod", "*v1.ReplicaSet", "*v1.Pod"},
+		},
+		"Reject invalid document": {
+			content:     `{"apiVersion": "v1", "kind": "Pod", "metadata": []}`,
+			expectError: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			objects, err := LoadObjects(strings.NewReader(tc.content))
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			var kinds []string
+			for _, obj := range objects {
+				kinds = append(kinds, fmt.Sprintf("%T", obj))
+			}
+			assert.Equal(t, tc.expectedKinds, kinds)
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package kubernetes
+
+import (
+	"context"
+	"expvar"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/flowcontrol"
+	"k8s.io/utils/clock"
+)
+
+// MaxRetryAfter caps the pause asked by the Retry-After header of a 429 response
+const MaxRetryAfter = time.Minute
+
+// Throttling publishes the client-side throttling of every cluster as the client_throttling expvar
+// Every cluster ("default" outside multi-cluster mode) maps to:
+//   - requests: requests that went through the rate limiter
+//   - throttled_seconds: total time requests waited for the rate limiter and Retry-After pauses
+//   - too_many_requests: 429 responses of the API server
+var Throttling = expvar.NewMap("client_throttling")
+
+// Throttling stats
+const (
+	statRequests         = "requests"
+	statThrottledSeconds = "throttled_seconds"
+	statTooManyRequests  = "too_many_requests"
+)
+
+// rateLimiter is the token bucket shared by every request of a client, and kept across kubeconfig reloads
+// A 429 response pauses all the requests of the client for its Retry-After, not just the retried one
+type rateLimiter struct {
+	flowcontrol.RateLimiter
+	clock clock.Clock
+	stats *expvar.Map
+	log   *slog.Logger
+
+	mu    sync.Mutex
+	until time.Time
+}
+
+// newRateLimiter returns a token bucket of qps and burst (the client-go defaults when zero)
+// Its stats are published under cluster in Throttling
+func newRateLimiter(qps float32, burst int, clk clock.Clock, cluster string, log *slog.Logger) *rateLimiter {
+	if qps <= 0 {
+		qps = rest.DefaultQPS
+	}
+	if burst <= 0 {
+		burst = rest.DefaultBurst
+	}
+	if cluster == "" {
+		cluster = "default"
+	}
+	stats, ok := Throttling.Get(cluster).(*expvar.Map)
+	if !ok {
+		stats = new(expvar.Map).Init()
+		Throttling.Set(cluster, stats)
+	}
+	return &rateLimiter{
+		RateLimiter: flowcontrol.NewTokenBucketRateLimiter(qps, burst),
+		clock:       clk,
+		stats:       stats,
+		log:         log,
+	}
+}
+
+// Wait blocks until the client is not paused anymore and a token is available, or ctx is done
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	start := l.clock.Now()
+	defer func() {
+		l.stats.Add(statRequests, 1)
+		l.stats.AddFloat(statThrottledSeconds, l.clock.Since(start).Seconds())
+	}()
+
+	l.mu.Lock()
+	pause := l.until.Sub(start)
+	l.mu.Unlock()
+	if pause > 0 {
+		timer := l.clock.NewTimer(pause)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C():
+		}
+	}
+	return l.RateLimiter.Wait(ctx)
+}
+
+// pause holds the requests of the client for d, unless they are already held for longer
+func (l *rateLimiter) pause(d time.Duration) {
+	until := l.clock.Now().Add(min(d, MaxRetryAfter))
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until.After(l.until) {
+		l.until = until
+	}
+}
+
+// wrap returns a transport that pauses the client when the API server answers 429 Too Many Requests
+// The request itself is retried by client-go, after the same Retry-After
+func (l *rateLimiter) wrap(rt http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := rt.RoundTrip(req)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+			return resp, err
+		}
+		l.stats.Add(statTooManyRequests, 1)
+		seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		retryAfter := time.Duration(max(seconds, 1)) * time.Second
+		l.pause(retryAfter)
+		// API Priority and Fairness names the flow schema and priority level that rejected the request
+		l.log.Warn("The API server is throttling requests",
+			"retry_after", retryAfter,
+			"flow_schema", resp.Header.Get("X-Kubernetes-PF-FlowSchema-UID"),
+			"priority_level", resp.Header.Get("X-Kubernetes-PF-PriorityLevel-UID"),
+			"path", req.URL.Path,
+		)
+		return resp, nil
+	})
+}
+
+// roundTripperFunc adapts a function to http.RoundTripper
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,91 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/clock"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestRateLimiterRetryAfter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", r.URL.Query().Get("retry-after"))
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	clk := testingclock.NewFakeClock(time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC))
+	l := newRateLimiter(100, 100, clk, "test-retry-after", slog.Default())
+	rt := l.wrap(http.DefaultTransport)
+	get := func(retryAfter string) {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"?retry-after="+retryAfter, nil)
+		require.NoError(t, err)
+		resp, err := rt.RoundTrip(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	}
+	get("3")
+	// a shorter Retry-After does not shorten the pause
+	get("1")
+
+	done := make(chan error)
+	go func() {
+		done <- l.Wait(context.TODO())
+	}()
+	assert.Eventually(t, clk.HasWaiters, time.Second, time.Millisecond)
+	clk.Step(2 * time.Second)
+	select {
+	case <-done:
+		t.Fatal("Wait returned before the Retry-After elapsed")
+	case <-time.After(10 * time.Millisecond):
+	}
+	clk.Step(time.Second)
+	require.NoError(t, <-done)
+
+	stats := Throttling.Get("test-retry-after").(*expvar.Map)
+	assert.Equal(t, "2", stats.Get(statTooManyRequests).String())
+	assert.Equal(t, "1", stats.Get(statRequests).String())
+	assert.Equal(t, "3", stats.Get(statThrottledSeconds).String())
+
+	// a cancelled request does not wait for the pause
+	get("60")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, l.Wait(ctx), context.Canceled)
+}
+
+func TestRateLimiterSharedAcrossReloads(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&corev1.EventList{})
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "config")
+	writeKubeconfig(t, path, server.URL)
+	clt, err := NewK8sClient(ClientOptions{Cluster: "test-shared", Kubeconfig: path, Clock: clock.RealClock{}})
+	require.NoError(t, err)
+	limiter := clt.limiter
+
+	_, err = clt.GetEvents(context.TODO(), "default", "BackOff", "")
+	require.NoError(t, err)
+	require.NoError(t, clt.loadKubeconfig())
+	_, err = clt.GetEvents(context.TODO(), "default", "BackOff", "")
+	require.NoError(t, err)
+
+	assert.Same(t, limiter, clt.limiter)
+	stats := Throttling.Get("test-shared").(*expvar.Map)
+	assert.Equal(t, "2", stats.Get(statRequests).String())
+}
@@ -7,11 +7,36 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	types "k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/utils/clock"
 )
 
 // kubeClient holds K8s parameters
 type kubeClient struct {
-	clientSet kubernetes.Interface
+	clientSet         kubernetes.Interface
+	pageSize          int64
+	opts              ClientOptions
+	inCluster         bool
+	kubeconfigModTime time.Time
+	clock             clock.PassiveClock
+	limiter           *rateLimiter
+}
+
+// ClientOptions holds the settings used to build the K8s client
+// QPS and Burst size the token bucket shared by every request of the client (the client-go defaults when zero),
+// a zero Timeout disables the request timeout
+// A nil Clock uses the wall clock
+// Cluster names the cluster in multi-cluster mode: its logs are labeled with it and it is always reached
+// through Kubeconfig, using Context (the current context of the kubeconfig when empty)
+type ClientOptions struct {
+	Cluster    string
+	Kubeconfig string
+	Context    string
+	PageSize   int64
+	QPS        float32
+	Burst      int
+	UserAgent  string
+	Timeout    time.Duration
+	Clock      clock.Clock
 }
 
 // PodDetails holds data associated with a Pod
@@ -20,22 +45,74 @@ type PodDetails struct {
 	PodName           string
 	PodNamespace      string
 	ResourceVersion   string
+	Labels            map[string]string
+	NodeName          string
 	OwnerReferences   []metav1.OwnerReference
 	Phase             v1.PodPhase
+	Ready             bool
 	ContainerStatuses []v1.ContainerStatus
 	CreationTimestamp time.Time
 	DeletionTimestamp *metav1.Time
 }
 
+// Verdict holds the result of PodChecks for a Pod
+// Reason explains why the Pod must not be restarted and is nil when Restart is true
+// Checks holds the result of every Check in pipeline order
+// Pod is nil when the Pod does not exist anymore
+type Verdict struct {
+	Pod     *PodDetails
+	Restart bool
+	Reason  error
+	Checks  []CheckResult
+}
+
 // PodEvent holds events data associated with a Pod
+// UID and ResourceVersion are the ones of the Pod, EventUID and EventResourceVersion the ones of the Event
 type PodEvent struct {
-	UID             types.UID
-	PodName         string
-	PodNamespace    string
-	ResourceVersion string
-	EventType       string
-	Reason          string
-	Message         string
-	FirstTimestamp  time.Time
-	LastTimestamp   time.Time
+	UID                  types.UID `json:"uid"`
+	PodName              string    `json:"pod"`
+	PodNamespace         string    `json:"namespace"`
+	ResourceVersion      string    `json:"resourceVersion,omitempty"`
+	EventUID             types.UID `json:"eventUID,omitempty"`
+	EventResourceVersion string    `json:"eventResourceVersion,omitempty"`
+	EventType            string    `json:"type"`
+	Reason               string    `json:"reason"`
+	Message              string    `json:"message"`
+	FirstTimestamp       time.Time `json:"firstTimestamp"`
+	LastTimestamp        time.Time `json:"lastTimestamp"`
+}
+
+// DeletePolicy holds the options used when deleting a Pod
+// A nil GracePeriodSeconds respects the terminationGracePeriodSeconds from the Pod spec
+type DeletePolicy struct {
+	GracePeriodSeconds *int64                      `json:"gracePeriodSeconds,omitempty"`
+	PropagationPolicy  *metav1.DeletionPropagation `json:"propagationPolicy,omitempty"`
+}
+
+// ActionSpec selects the Action used to remediate a Pod and holds its settings
+// An empty Action deletes the Pod; DeletePolicy is used by the delete and evict actions,
+// Labels by the label action, Annotations by the annotate action and WebhookURL by the exec-webhook action
+type ActionSpec struct {
+	Action       string            `json:"action,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+	WebhookURL   string            `json:"webhookURL,omitempty"`
+	DeletePolicy `json:",inline"`
+}
+
+// NamespaceFilter selects namespaces by name and label
+// Include and Exclude hold namespace names or glob patterns (eg: "team-*"); an empty Include means all namespaces
+// Scoped restricts pod-restarter to namespaced API calls, so that Roles in the Include namespaces are enough:
+// Include must then hold namespace names only and Selector must be empty
+type NamespaceFilter struct {
+	Include  []string `json:"include,omitempty"`
+	Exclude  []string `json:"exclude,omitempty"`
+	Selector string   `json:"selector,omitempty"`
+	Scoped   bool     `json:"scoped,omitempty"`
+}
+
+// PodFilter restricts the Pods pod-restarter acts on to selected namespaces and Pod labels
+type PodFilter struct {
+	Namespaces  NamespaceFilter `json:"namespaces,omitempty"`
+	PodSelector string          `json:"podSelector,omitempty"`
 }
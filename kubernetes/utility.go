@@ -4,55 +4,78 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"strings"
 	"time"
-)
 
-// PodChecks returns nil if Pod
-// 1. exists
-// 2. has Owner
-// 3. has not been scheduled to be deleted
-// 4. and is not in a Healthy state (eg: Pending, Failed or Running with unhealthy containers)
-func (c *kubeClient) PodChecks(ctx context.Context, podName, podNamespace string) error {
-	// verify if Pod exists
-	podInfo, err := c.GetPodDetails(ctx, podName, podNamespace)
-	if err != nil {
-		return err
-	}
+	"github.com/andreistefanciprian/pod-restarter-go/logging"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+)
 
-	// verify Pod has owner
-	err = podInfo.verifyPodHasOwner()
-	if err != nil {
-		return err
+// PodChecks returns a Verdict that allows the Pod to be restarted if Pod exists and passes every Check in order
+// When checks is nil the DefaultChecks run:
+// 1. has Owner
+// 2. has not been scheduled to be deleted
+// 3. and is not in a Healthy state (eg: Pending, Failed or Running with unhealthy containers)
+// Checks after the first failing one are reported as skipped and the Verdict Reason holds the first failure
+// (eg: wrapping ErrPodNotFound, ErrNoOwner, ErrPodTerminating or ErrPodHealthy)
+// error is only returned when the Pod cannot be checked
+func (c *kubeClient) PodChecks(ctx context.Context, podName, podNamespace string, checks []Check) (*Verdict, error) {
+	if checks == nil {
+		var err error
+		checks, err = LookupChecks(DefaultChecks)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	// verify Pod is scheduled to be deleted
-	err = podInfo.verifyPodScheduledToBeDeleted()
-	if err != nil {
-		return err
+	// verify if Pod exists
+	podInfo, err := c.GetPodDetails(ctx, podName, podNamespace)
+	if errors.Is(err, ErrPodNotFound) {
+		verdict := &Verdict{Reason: err}
+		verdict.Checks = append(verdict.Checks, CheckResult{Name: checkExists, Outcome: CheckFailed, Reason: err})
+		for _, check := range checks {
+			verdict.Checks = append(verdict.Checks, CheckResult{Name: check.Name(), Outcome: CheckSkipped})
+		}
+		return verdict, nil
+	} else if err != nil {
+		return nil, err
 	}
 
-	// verify Pod is in an Unhealthy state
-	err = podInfo.verifyPodStatus()
-	if err != nil {
-		return nil
-	} else {
-		msg := fmt.Sprintf("Pod is in a Healthy State: %s/%s", podNamespace, podName)
-		return errors.New(msg)
+	verdict := &Verdict{Pod: podInfo, Restart: true}
+	verdict.Checks = append(verdict.Checks, CheckResult{Name: checkExists, Outcome: CheckPassed})
+	for _, check := range checks {
+		if !verdict.Restart {
+			verdict.Checks = append(verdict.Checks, CheckResult{Name: check.Name(), Outcome: CheckSkipped})
+			continue
+		}
+		err = check.Check(ctx, c.clientSet, podInfo)
+		if err != nil {
+			verdict.Restart = false
+			verdict.Reason = err
+			verdict.Checks = append(verdict.Checks, CheckResult{Name: check.Name(), Outcome: CheckFailed, Reason: err})
+			continue
+		}
+		verdict.Checks = append(verdict.Checks, CheckResult{Name: check.Name(), Outcome: CheckPassed})
 	}
+	return verdict, nil
 }
 
-// verifyPodStatus returns error if Pod is in a Pending, Failed or Running (with unhealthy containers) state
+// verifyPodStatus returns nil if Pod is in a Pending, Failed, Unknown or Running (with unhealthy containers) state
+// The returned error wraps ErrPodHealthy
 func (p *PodDetails) verifyPodStatus() error {
 
 	switch p.Phase {
 
-	case "Pending":
-		msg := fmt.Sprintf(
-			"Pod is in a %s state: %s/%s",
-			p.Phase, p.PodNamespace, p.PodName,
+	case "Pending", "Failed", "Unknown":
+		slog.Debug("Pod is failing",
+			logging.KeyNamespace, p.PodNamespace,
+			logging.KeyPod, p.PodName,
+			"phase", p.Phase,
 		)
-		return errors.New(msg)
+		return nil
 
 	case "Running":
 		if len(p.ContainerStatuses) != 0 {
@@ -63,54 +86,38 @@ func (p *PodDetails) verifyPodStatus() error {
 				if cst.State.Terminated.Reason == "Completed" && cst.State.Terminated.ExitCode == 0 {
 					continue
 				}
-				msg := fmt.Sprintf(
-					"Pod is in a %s state and has issues: %s/%s",
-					p.Phase, p.PodNamespace, p.PodName,
+				slog.Debug("Pod has failing containers",
+					logging.KeyNamespace, p.PodNamespace,
+					logging.KeyPod, p.PodName,
+					"phase", p.Phase,
+					"container", cst.Name,
 				)
-				return errors.New(msg)
+				return nil
 			}
 
-			log.Printf(
-				"Pod is in a %s state and is healthy: %s/%s",
-				p.Phase, p.PodNamespace, p.PodName,
+			return fmt.Errorf(
+				"%w (%s with healthy containers): %s/%s",
+				ErrPodHealthy, p.Phase, p.PodNamespace, p.PodName,
 			)
-			return nil
-
 		}
-		log.Printf(
-			"Pod is in a %s state and has been evacuated?: %s/%s\n%+v",
-			p.Phase, p.PodNamespace, p.PodName,
-			p.ContainerStatuses,
+		return fmt.Errorf(
+			"%w (%s without container statuses, probably evacuated): %s/%s",
+			ErrPodHealthy, p.Phase, p.PodNamespace, p.PodName,
 		)
-		return nil
-
-	case "Failed":
-		msg := fmt.Sprintf(
-			"Pod is in a %s state: %s/%s",
-			p.Phase, p.PodNamespace, p.PodName,
-		)
-		return errors.New(msg)
 
 	case "Succeeded":
-		log.Printf(
-			"Pod is in a %s state: %s/%s",
-			p.Phase, p.PodNamespace, p.PodName,
-		)
-		return nil
-
-	case "Unknown":
-		msg := fmt.Sprintf(
-			"Pod is in a %s state: %s/%s",
-			p.Phase, p.PodNamespace, p.PodName,
+		return fmt.Errorf(
+			"%w (%s): %s/%s",
+			ErrPodHealthy, p.Phase, p.PodNamespace, p.PodName,
 		)
-		return errors.New(msg)
 	}
 
-	msg := fmt.Sprintf(
-		"Pod is in a %s state ????????: %s/%s",
-		p.Phase, p.PodNamespace, p.PodName,
+	slog.Debug("Pod is in an unexpected phase",
+		logging.KeyNamespace, p.PodNamespace,
+		logging.KeyPod, p.PodName,
+		"phase", p.Phase,
 	)
-	return errors.New(msg)
+	return nil
 }
 
 // verify if element in slice
@@ -124,30 +131,89 @@ func contains(elems []string, v string) bool {
 }
 
 // verifyPodHasOwner returns nil if Pod has owner
+// The returned error wraps ErrNoOwner
 func (p *PodDetails) verifyPodHasOwner() error {
 	if len(p.OwnerReferences) > 0 {
 		return nil
 	}
-	msg := fmt.Sprintf(
-		"Pod does not have owner/controller: %s/%s",
-		p.PodNamespace, p.PodName,
+	return fmt.Errorf(
+		"%w: %s/%s",
+		ErrNoOwner, p.PodNamespace, p.PodName,
 	)
-	return errors.New(msg)
 }
 
 // verifyPodScheduledToBeDeleted returns nil if Pod is not scheduled to be deleted
+// The returned error wraps ErrPodTerminating
 func (p *PodDetails) verifyPodScheduledToBeDeleted() error {
 	// verify Pod has not been scheduled to be deleted
 	if p.DeletionTimestamp != nil {
-		msg := fmt.Sprintf(
-			"Pod has already been scheduled to be deleted: %s/%s\n%v",
-			p.PodNamespace, p.PodName, p.DeletionTimestamp,
+		return fmt.Errorf(
+			"%w at %s: %s/%s",
+			ErrPodTerminating, p.DeletionTimestamp.UTC().Format(time.RFC3339), p.PodNamespace, p.PodName,
 		)
-		return errors.New(msg)
 	}
 	return nil
 }
 
+// Owner returns the Kind/Name of the Pod controller, or of its first owner when there is no controller
+func (p *PodDetails) Owner() string {
+	ref := controllerOf(p.OwnerReferences)
+	if ref == nil {
+		return ""
+	}
+	return ref.Kind + "/" + ref.Name
+}
+
+// newPodDetails returns the PodDetails of a Pod
+func newPodDetails(pod *v1.Pod) PodDetails {
+	return PodDetails{
+		UID:               pod.ObjectMeta.UID,
+		PodName:           pod.ObjectMeta.Name,
+		PodNamespace:      pod.ObjectMeta.Namespace,
+		ResourceVersion:   pod.ObjectMeta.ResourceVersion,
+		Labels:            pod.ObjectMeta.Labels,
+		NodeName:          pod.Spec.NodeName,
+		Phase:             pod.Status.Phase,
+		Ready:             podReady(pod),
+		ContainerStatuses: pod.Status.ContainerStatuses,
+		OwnerReferences:   pod.ObjectMeta.OwnerReferences,
+		CreationTimestamp: pod.ObjectMeta.CreationTimestamp.Time,
+		DeletionTimestamp: pod.ObjectMeta.DeletionTimestamp,
+	}
+}
+
+// podReady returns true if the Pod has the Ready condition
+func podReady(pod *v1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == v1.PodReady {
+			return c.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// newPodEvent returns the PodEvent of an Event
+func newPodEvent(event *v1.Event) PodEvent {
+	return PodEvent{
+		UID:                  event.InvolvedObject.UID,
+		PodName:              event.InvolvedObject.Name,
+		PodNamespace:         event.InvolvedObject.Namespace,
+		ResourceVersion:      event.InvolvedObject.ResourceVersion,
+		EventUID:             event.UID,
+		EventResourceVersion: event.ResourceVersion,
+		Reason:               event.Reason,
+		EventType:            event.Type,
+		Message:              event.Message,
+		FirstTimestamp:       event.FirstTimestamp.Time,
+		LastTimestamp:        event.LastTimestamp.Time,
+	}
+}
+
+// Matches returns true if the Event has Reason and its Message contains message
+func (e PodEvent) Matches(reason, message string) bool {
+	return e.Reason == reason && strings.Contains(e.Message, message)
+}
+
 // getUniqueListOfPods returns a unique list of Pods that have Events that match Reason
 func getUniqueListOfPods(events []PodEvent) map[string]string {
 
@@ -164,21 +230,88 @@ func getUniqueListOfPods(events []PodEvent) map[string]string {
 	return uniquePodList
 }
 
-// removeOlderEvents returns a slice of latest Events not older than eventMaxAge
-func removeOlderEvents(events []PodEvent, eventMaxAge time.Time) []PodEvent {
-	var latestEvents []PodEvent
-	for _, event := range events {
+// listOptions returns the options for the first page of a paginated list request
+// ResourceVersion "0" lets the API server answer from its watch cache instead of etcd
+func listOptions(pageSize int64) metav1.ListOptions {
+	return metav1.ListOptions{
+		Limit:           pageSize,
+		ResourceVersion: "0",
+	}
+}
+
+// nextPage updates list options to request the page after continueToken
+// ResourceVersion must be unset because the continue token already pins the snapshot
+func nextPage(opts *metav1.ListOptions, continueToken string) {
+	opts.Continue = continueToken
+	opts.ResourceVersion = ""
+}
 
-		if event.LastTimestamp.Before(eventMaxAge) {
+// keepSelectedPods returns a slice of Events that belong to the selected Pods
+func keepSelectedPods(events []PodEvent, selectedPods map[types.UID]bool) []PodEvent {
+	var selectedEvents []PodEvent
+	for _, event := range events {
+		if !selectedPods[event.UID] {
 			continue
 		}
-		latestEvents = append(latestEvents, event)
+		selectedEvents = append(selectedEvents, event)
+	}
+	return selectedEvents
+}
+
+// Validate returns error if DeletePolicy has a negative grace period or an unknown propagation policy
+func (d DeletePolicy) Validate() error {
+	if d.GracePeriodSeconds != nil && *d.GracePeriodSeconds < 0 {
+		return fmt.Errorf("gracePeriodSeconds must not be negative: %d", *d.GracePeriodSeconds)
+	}
+	if d.PropagationPolicy != nil {
+		switch *d.PropagationPolicy {
+		case metav1.DeletePropagationOrphan, metav1.DeletePropagationBackground, metav1.DeletePropagationForeground:
+		default:
+			return fmt.Errorf("unknown propagationPolicy: %s", *d.PropagationPolicy)
+		}
+	}
+	return nil
+}
+
+// deleteOptions converts DeletePolicy into the options sent with the delete request
+func (d DeletePolicy) deleteOptions() metav1.DeleteOptions {
+	return metav1.DeleteOptions{
+		GracePeriodSeconds: d.GracePeriodSeconds,
+		PropagationPolicy:  d.PropagationPolicy,
+	}
+}
+
+// String returns the grace period and propagation policy in a format suitable for logging
+func (d DeletePolicy) String() string {
+	return fmt.Sprintf("gracePeriod: %s, propagationPolicy: %s", d.gracePeriod(), d.propagationPolicy())
+}
+
+// LogValue groups the grace period and propagation policy in structured log records
+func (d DeletePolicy) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("grace_period", d.gracePeriod()),
+		slog.String("propagation_policy", d.propagationPolicy()),
+	)
+}
+
+// gracePeriod returns the grace period or "pod spec" when terminationGracePeriodSeconds is respected
+func (d DeletePolicy) gracePeriod() string {
+	if d.GracePeriodSeconds == nil {
+		return "pod spec"
+	}
+	return fmt.Sprintf("%ds", *d.GracePeriodSeconds)
+}
+
+// propagationPolicy returns the propagation policy or "default" when the API server default is used
+func (d DeletePolicy) propagationPolicy() string {
+	if d.PropagationPolicy == nil {
+		return "default"
 	}
-	return latestEvents
+	return string(*d.PropagationPolicy)
 }
 
 // timeTrack calculates how long it takes to execute a function
 func timeTrack(start time.Time, name string) {
 	elapsed := time.Since(start)
-	log.Printf("%v ran in %v \n", name, elapsed)
+	slog.Debug("Function timing", "function", name, "elapsed", elapsed)
 }
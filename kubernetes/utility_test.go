@@ -1,6 +1,7 @@
 package kubernetes
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
@@ -8,7 +9,11 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func TestVerifyPodStatus(t *testing.T) {
@@ -60,11 +65,11 @@ func TestVerifyPodStatus(t *testing.T) {
 							RestartCount: 0,
 						},
 					},
-					CreationTimestamp: time.Now(),
+					CreationTimestamp: testNow,
 					DeletionTimestamp: nil,
 				},
 			},
-			expected: Expected{err: fmt.Errorf("Pod is in a Pending state: default/foo")},
+			expected: Expected{err: nil},
 		},
 		"Verify Pod is in Running Phase with failed container": {
 			inputs: Inputs{
@@ -116,11 +121,59 @@ func TestVerifyPodStatus(t *testing.T) {
 							RestartCount: 0,
 						},
 					},
-					CreationTimestamp: time.Now(),
+					CreationTimestamp: testNow,
 					DeletionTimestamp: nil,
 				},
 			},
-			expected: Expected{err: fmt.Errorf("Pod is in a Running state and has issues: default/foo")},
+			expected: Expected{err: nil},
+		},
+		"Verify Pod is in Running Phase with healthy containers": {
+			inputs: Inputs{
+				pod: PodDetails{
+					PodName:      "foo",
+					PodNamespace: "default",
+					Phase:        v1.PodRunning,
+					ContainerStatuses: []v1.ContainerStatus{
+						{
+							Name: "good_container",
+							State: v1.ContainerState{
+								Running: &v1.ContainerStateRunning{},
+							},
+							Ready: true,
+						},
+						{
+							Name: "completed_container",
+							State: v1.ContainerState{
+								Terminated: &v1.ContainerStateTerminated{
+									Reason:   "Completed",
+									ExitCode: 0,
+								},
+							},
+						},
+					},
+				},
+			},
+			expected: Expected{err: fmt.Errorf("Pod is in a Healthy state (Running with healthy containers): default/foo")},
+		},
+		"Verify Pod is in Succeeded Phase": {
+			inputs: Inputs{
+				pod: PodDetails{
+					PodName:      "foo",
+					PodNamespace: "default",
+					Phase:        v1.PodSucceeded,
+				},
+			},
+			expected: Expected{err: fmt.Errorf("Pod is in a Healthy state (Succeeded): default/foo")},
+		},
+		"Verify Pod is in Failed Phase": {
+			inputs: Inputs{
+				pod: PodDetails{
+					PodName:      "foo",
+					PodNamespace: "default",
+					Phase:        v1.PodFailed,
+				},
+			},
+			expected: Expected{err: nil},
 		},
 	}
 
@@ -134,6 +187,7 @@ func TestVerifyPodStatus(t *testing.T) {
 			if tc.expected.err != nil {
 				require.Error(tc.expected.err)
 				assert.EqualError(err, tc.expected.err.Error(), "Expected error: %v Got: %v", tc.expected.err, err)
+				assert.ErrorIs(err, ErrPodHealthy)
 			} else {
 				require.NoError(err)
 			}
@@ -193,6 +247,7 @@ func TestVerifyPodHasOwner(t *testing.T) {
 			if tc.expected.err != nil {
 				require.Error(tc.expected.err)
 				assert.EqualError(err, tc.expected.err.Error(), "Expected error: %v Got: %v", tc.expected.err, err)
+				assert.ErrorIs(err, ErrNoOwner)
 			} else {
 				require.NoError(err)
 			}
@@ -201,8 +256,8 @@ func TestVerifyPodHasOwner(t *testing.T) {
 }
 
 func TestVerifyPodScheduledToBeDeleted(t *testing.T) {
-	deletionTimestamp := &metav1.Time{Time: time.Now()}
-	creationTimestamp := time.Now().Add(-time.Second * 10)
+	deletionTimestamp := &metav1.Time{Time: testNow}
+	creationTimestamp := testNow.Add(-time.Second * 10)
 
 	type Inputs struct {
 		pod PodDetails
@@ -236,7 +291,7 @@ func TestVerifyPodScheduledToBeDeleted(t *testing.T) {
 					DeletionTimestamp: deletionTimestamp,
 				},
 			},
-			expected: Expected{err: fmt.Errorf("Pod has already been scheduled to be deleted: default/foo\n%v", deletionTimestamp)},
+			expected: Expected{err: fmt.Errorf("Pod has already been scheduled to be deleted at %s: default/foo", deletionTimestamp.UTC().Format(time.RFC3339))},
 		},
 	}
 
@@ -250,9 +305,134 @@ func TestVerifyPodScheduledToBeDeleted(t *testing.T) {
 			if tc.expected.err != nil {
 				require.Error(tc.expected.err)
 				assert.EqualError(err, tc.expected.err.Error(), "Expected error: %v Got: %v", tc.expected.err, err)
+				assert.ErrorIs(err, ErrPodTerminating)
 			} else {
 				require.NoError(err)
 			}
 		})
 	}
 }
+
+func TestPodChecks(t *testing.T) {
+	owned := func(pod *v1.Pod) *v1.Pod {
+		controller := true
+		pod.OwnerReferences = []metav1.OwnerReference{
+			{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "foo-abc", UID: "rs1", Controller: &controller},
+		}
+		return pod
+	}
+	terminating := func(pod *v1.Pod) *v1.Pod {
+		pod.DeletionTimestamp = &metav1.Time{Time: testNow}
+		return pod
+	}
+
+	tests := map[string]struct {
+		mockedPods     []runtime.Object
+		expectRestart  bool
+		expectedReason error
+	}{
+		"Verify owned Pending Pod is restarted": {
+			mockedPods:    []runtime.Object{owned(makePod("foo", "default", 1, v1.PodPending, "uid1"))},
+			expectRestart: true,
+		},
+		"Verify missing Pod is not restarted": {
+			mockedPods:     []runtime.Object{},
+			expectedReason: ErrPodNotFound,
+		},
+		"Verify bare Pod is not restarted": {
+			mockedPods:     []runtime.Object{makePod("foo", "default", 1, v1.PodPending, "uid1")},
+			expectedReason: ErrNoOwner,
+		},
+		"Verify terminating Pod is not restarted": {
+			mockedPods:     []runtime.Object{terminating(owned(makePod("foo", "default", 1, v1.PodPending, "uid1")))},
+			expectedReason: ErrPodTerminating,
+		},
+		"Verify healthy Pod is not restarted": {
+			mockedPods:     []runtime.Object{owned(makePod("foo", "default", 1, v1.PodSucceeded, "uid1"))},
+			expectedReason: ErrPodHealthy,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var clt kubeClient
+			clt.clientSet = fake.NewSimpleClientset(tc.mockedPods...)
+
+			verdict, err := clt.PodChecks(context.TODO(), "foo", "default", nil)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectRestart, verdict.Restart)
+			if tc.expectRestart {
+				assert.NoError(t, verdict.Reason)
+				assert.Equal(t, "ReplicaSet/foo-abc", verdict.Pod.Owner())
+			} else {
+				assert.ErrorIs(t, verdict.Reason, tc.expectedReason)
+			}
+		})
+	}
+}
+
+func TestPodChecksAPIError(t *testing.T) {
+	clientSet := fake.NewSimpleClientset()
+	clientSet.PrependReactor("get", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewServiceUnavailable("apiserver is down")
+	})
+	clt := kubeClient{clientSet: clientSet}
+
+	// API failures are returned as errors, not as a Verdict
+	verdict, err := clt.PodChecks(context.TODO(), "foo", "default", nil)
+	assert.Nil(t, verdict)
+	require.Error(t, err)
+	assert.True(t, apierrors.IsServiceUnavailable(err))
+	assert.NotErrorIs(t, err, ErrPodNotFound)
+}
+
+func TestDeletePolicyValidate(t *testing.T) {
+	validGracePeriod := int64(0)
+	negativeGracePeriod := int64(-5)
+	validPropagation := metav1.DeletePropagationForeground
+	unknownPropagation := metav1.DeletionPropagation("Sideways")
+
+	tests := map[string]struct {
+		policy DeletePolicy
+		err    error
+	}{
+		"Verify empty policy respects Pod spec": {
+			policy: DeletePolicy{},
+			err:    nil,
+		},
+		"Verify valid grace period and propagation policy": {
+			policy: DeletePolicy{GracePeriodSeconds: &validGracePeriod, PropagationPolicy: &validPropagation},
+			err:    nil,
+		},
+		"Verify negative grace period is rejected": {
+			policy: DeletePolicy{GracePeriodSeconds: &negativeGracePeriod},
+			err:    fmt.Errorf("gracePeriodSeconds must not be negative: -5"),
+		},
+		"Verify unknown propagation policy is rejected": {
+			policy: DeletePolicy{PropagationPolicy: &unknownPropagation},
+			err:    fmt.Errorf("unknown propagationPolicy: Sideways"),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := tc.policy.Validate()
+			if tc.err != nil {
+				assert.EqualError(t, err, tc.err.Error())
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDeletePolicyString(t *testing.T) {
+	gracePeriod := int64(30)
+	propagation := metav1.DeletePropagationBackground
+
+	assert.Equal(t, "gracePeriod: pod spec, propagationPolicy: default", DeletePolicy{}.String())
+	assert.Equal(t,
+		"gracePeriod: 30s, propagationPolicy: Background",
+		DeletePolicy{GracePeriodSeconds: &gracePeriod, PropagationPolicy: &propagation}.String(),
+	)
+}
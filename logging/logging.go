@@ -0,0 +1,49 @@
+// Package logging configures the structured logger used by pod-restarter
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Keys shared by all log records so they can be parsed by log pipelines
+const (
+	KeyCluster   = "cluster"
+	KeyNamespace = "namespace"
+	KeyPod       = "pod"
+	KeyUID       = "uid"
+	KeyRule      = "rule"
+	KeyOwner     = "owner"
+	KeyAction    = "action"
+	KeyOutcome   = "outcome"
+	KeyDryRun    = "dry_run"
+	KeyError     = "error"
+)
+
+// New returns a logger writing text or json records to w at or above level (debug, info, warn or error)
+func New(w io.Writer, format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	err := lvl.UnmarshalText([]byte(level))
+	if err != nil {
+		msg := fmt.Sprintf("unknown log level %q: expected debug, info, warn or error", level)
+		return nil, errors.New(msg)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	switch strings.ToLower(format) {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	msg := fmt.Sprintf("unknown log format %q: expected text or json", format)
+	return nil, errors.New(msg)
+}
+
+// Err returns an attribute holding err under KeyError
+func Err(err error) slog.Attr {
+	return slog.Any(KeyError, err)
+}
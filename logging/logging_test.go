@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	tests := map[string]struct {
+		format      string
+		level       string
+		expectError bool
+	}{
+		"Text format":            {format: "text", level: "info"},
+		"JSON format":            {format: "json", level: "debug"},
+		"Uppercase format":       {format: "JSON", level: "WARN"},
+		"Unknown format":         {format: "logfmt", level: "info", expectError: true},
+		"Unknown level":          {format: "text", level: "verbose", expectError: true},
+		"Empty level and format": {format: "", level: "", expectError: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			logger, err := New(&bytes.Buffer{}, tc.format, tc.level)
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, logger)
+		})
+	}
+}
+
+func TestNewJSONRecord(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "json", "info")
+	require.NoError(t, err)
+
+	logger.Debug("Hidden record")
+	logger.Info("Deleted Pod",
+		KeyNamespace, "default",
+		KeyPod, "foo",
+		KeyDryRun, false,
+		Err(errors.New("boom")),
+	)
+
+	// debug records are dropped at info level, so there is exactly one record
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "INFO", record["level"])
+	assert.Equal(t, "Deleted Pod", record["msg"])
+	assert.Equal(t, "default", record[KeyNamespace])
+	assert.Equal(t, "foo", record[KeyPod])
+	assert.Equal(t, false, record[KeyDryRun])
+	assert.Equal(t, "boom", record[KeyError])
+}
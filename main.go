@@ -6,40 +6,175 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
-	"log"
+	"fmt"
+	"log/slog"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/andreistefanciprian/pod-restarter-go/audit"
+	"github.com/andreistefanciprian/pod-restarter-go/config"
+	"github.com/andreistefanciprian/pod-restarter-go/health"
 	k8s "github.com/andreistefanciprian/pod-restarter-go/kubernetes"
+	"github.com/andreistefanciprian/pod-restarter-go/logging"
+	"github.com/andreistefanciprian/pod-restarter-go/notify"
+	"github.com/andreistefanciprian/pod-restarter-go/reconciler"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/util/homedir"
+	"k8s.io/utils/clock"
 )
 
 // define variables
 var (
 	pollingInterval int
 	kubeconfig      *string
-	ctx             = context.TODO()
 	errorMessage    string
 	eventReason     string
 	namespace       string
 	dryRunMode      bool
-	healTime        time.Duration = 5 // allow Pending Pod time to self heal (seconds)
+	configFile      string
+	gracePeriod     int64
+	propagation     string
+	includeNs       stringList
+	excludeNs       stringList
+	nsSelector      string
+	namespaced      bool
+	preflightMode   string
+	podSelector     string
+	pageSize        int64
+	kubeAPIQPS      float64
+	kubeAPIBurst    int
+	workers         int
+	maxRemediations int
+	userAgent       string
+	requestTimeout  time.Duration
+	lookback        time.Duration
+	healthAddr      string
+	notifyURLs      stringList
+	notifyFormat    string
+	notifySecret    string
+	auditLog        string
+	auditKey        string
+	auditMaxSize    int64
+	auditBackups    int
+	paused          bool
+	minAvailable    string
+	contexts        stringList
+	kubeconfigs     stringList
+	pauseConfigMap  string
+	livenessCycles  int
+	logFormat       string
+	checkNames      stringList
+	actionName      string
+	webhookURL      string
+	podLabels       keyValues
+	podAnnotations  keyValues
+	logLevel        string
+	outputFormat    string
 )
 
+// stringList is a flag.Value that holds a comma separated list of strings
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*s = append(*s, item)
+		}
+	}
+	return nil
+}
+
+// keyValues is a flag.Value that holds a comma separated list of key=value pairs
+type keyValues map[string]string
+
+func (kv *keyValues) String() string {
+	var pairs []string
+	for k, v := range *kv {
+		pairs = append(pairs, k+"="+v)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (kv *keyValues) Set(value string) error {
+	if *kv == nil {
+		*kv = make(keyValues)
+	}
+	for _, pair := range strings.Split(value, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("expected key=value, got %q", pair)
+		}
+		(*kv)[k] = v
+	}
+	return nil
+}
+
 func initFlags() {
 	// define and parse cli params
 	flag.BoolVar(&dryRunMode, "dry-run", false, "enable dry run mode (no changes are made, only logged)")
-	flag.StringVar(&namespace, "namespace", "", "kubernetes namespace")
+	flag.StringVar(&namespace, "namespace", "", "kubernetes namespace (shorthand for a single --include-namespaces entry)")
+	flag.Var(&includeNs, "include-namespaces", "comma separated list of namespaces or glob patterns to look for failing Pods in (default: all namespaces)")
+	flag.Var(&excludeNs, "exclude-namespaces", "comma separated list of namespaces or glob patterns to ignore")
+	flag.BoolVar(&namespaced, "namespaced", false, "only make namespaced API calls in --include-namespaces, so that Roles in those namespaces are enough (no ClusterRole)")
+	flag.StringVar(&preflightMode, "preflight", preflightWarn, "permission review before run and once start: warn logs the missing permissions, fail also stops pod-restarter, off skips it")
+	flag.StringVar(&nsSelector, "namespace-selector", "", "label selector for namespaces to look for failing Pods in (eg: team=platform)")
+	flag.StringVar(&podSelector, "pod-selector", "", "label selector for Pods that can be restarted (eg: app=nginx)")
+	flag.Int64Var(&pageSize, "page-size", k8s.DefaultPageSize, "number of Events/Pods requested per page when listing")
+	flag.Float64Var(&kubeAPIQPS, "kube-api-qps", 5, "maximum queries per second to the kubernetes API server")
+	flag.IntVar(&kubeAPIBurst, "kube-api-burst", 10, "maximum burst of queries to the kubernetes API server")
+	flag.IntVar(&workers, "workers", reconciler.DefaultWorkers, "number of candidate Pods checked and remediated at the same time in every cluster")
+	flag.IntVar(&maxRemediations, "max-remediations", 0, "maximum number of Pods remediated per cycle in every cluster, shared by the workers; the others are deferred to the next cycles (0 disables the limit)")
+	flag.Var(&contexts, "contexts", "comma separated list of --kubeconfig contexts; every context is a cluster reconciled by its own loop (multi-cluster mode)")
+	flag.Var(&kubeconfigs, "kubeconfigs", "comma separated list of kubeconfig files; every file is a cluster reconciled by its own loop (multi-cluster mode)")
+	flag.StringVar(&userAgent, "user-agent", "pod-restarter", "user agent sent to the kubernetes API server")
+	flag.StringVar(&actionName, "action", k8s.ActionDelete, "action used to remediate failing Pods: delete, evict, rollout-restart, label, annotate or exec-webhook")
+	flag.StringVar(&webhookURL, "webhook-url", "", "URL the exec-webhook action POSTs failing Pods to")
+	flag.Var(&podLabels, "labels", "comma separated key=value labels added to failing Pods by the label action")
+	flag.Var(&podAnnotations, "annotations", "comma separated key=value annotations added to failing Pods by the annotate action")
+	flag.StringVar(&minAvailable, "min-available", "", "minimum of ready replicas (eg: 1 or 50%) the Deployment, StatefulSet or ReplicaSet of a remediated Pod keeps; adds the min-available check to every rule, replacing the global minAvailable of the config file (empty disables it)")
+	flag.Var(&checkNames, "checks", fmt.Sprintf("comma separated, ordered list of checks a Pod must pass before it is deleted (default %s)", strings.Join(k8s.DefaultChecks, ",")))
+	flag.Var(&notifyURLs, "notify-urls", "comma separated list of webhook URLs the remediation outcomes of every cycle are POSTed to")
+	flag.StringVar(&notifyFormat, "notify-format", notify.FormatGeneric, "payload format of the --notify-urls webhooks: generic or slack")
+	flag.StringVar(&notifySecret, "notify-secret", "", "secret the notification payloads are signed with (HMAC-SHA256) (default $POD_RESTARTER_NOTIFY_SECRET)")
+	flag.StringVar(&auditKey, "audit-key", "", "key the hash chain of the audit log is computed and verified with (HMAC-SHA256) (default $POD_RESTARTER_AUDIT_KEY)")
+	flag.StringVar(&auditLog, "audit-log", "", "path of the JSON lines audit log of every decision made by run and once (- writes to stdout, empty disables it)")
+	flag.Int64Var(&auditMaxSize, "audit-max-size", 100, "size in megabytes the audit log is rotated at (0 disables rotation)")
+	flag.IntVar(&auditBackups, "audit-max-backups", 5, "number of rotated audit log files kept")
+	flag.BoolVar(&paused, "paused", false, "defer every remediation (Pods are still scanned, checked, logged and audited)")
+	flag.StringVar(&pauseConfigMap, "pause-configmap", "", "namespace/name of a ConfigMap that pauses remediations while it is annotated with "+k8s.PauseAnnotation+"=true")
+	flag.StringVar(&healthAddr, "health-address", ":8080", "address /healthz, /readyz, /livez and /debug/vars are served on by the run command (empty disables them)")
+	flag.IntVar(&livenessCycles, "liveness-intervals", 3, "/livez fails when no cycle has completed within this number of polling intervals")
+	flag.StringVar(&outputFormat, "output", reconciler.OutputTable, "format of the scan, simulate and preflight reports: table, json or yaml")
+	flag.StringVar(&logFormat, "log-format", "text", "log output format: text or json")
+	flag.StringVar(&logLevel, "log-level", "info", "minimum log level: debug, info, warn or error")
+	flag.DurationVar(&requestTimeout, "request-timeout", 30*time.Second, "timeout for requests to the kubernetes API server (0 disables the timeout)")
 	flag.StringVar(&eventReason, "reason", "FailedCreatePodSandBox", "restart Pods that match Event Reason")
 	flag.IntVar(&pollingInterval, "polling-interval", 30, "number of seconds between iterations")
+	flag.DurationVar(&lookback, "startup-lookback", reconciler.DefaultLookback, "how far back matching Events are acted on at startup; afterwards every new Event is acted on once")
 	flag.StringVar(
 		&errorMessage,
 		"error-message",
 		"container veth name provided (eth0) already exists",
 		"number of seconds between iterations",
 	)
+	flag.StringVar(&configFile, "config", "", "(optional) path to a config file with rules; overrides --reason, --error-message, --grace-period and --propagation-policy")
+	flag.Int64Var(&gracePeriod, "grace-period", -1, "seconds given to a Pod to terminate gracefully; -1 respects terminationGracePeriodSeconds from the Pod spec")
+	flag.StringVar(&propagation, "propagation-policy", "", "deletion propagation policy: Orphan, Background or Foreground (default: API server default)")
 	if home := homedir.HomeDir(); home != "" {
 		kubeconfig = flag.String("kubeconfig", filepath.Join(home, ".kube", "config"), "(optional) absolute path to the kubeconfig file")
 	} else {
@@ -47,57 +182,296 @@ func initFlags() {
 	}
 }
 
-func main() {
+// podFilter builds a PodFilter from cli params
+func podFilter() k8s.PodFilter {
+	include := includeNs
+	if namespace != "" {
+		include = append(include, namespace)
+	}
+	return k8s.PodFilter{
+		Namespaces: k8s.NamespaceFilter{
+			Include:  include,
+			Exclude:  excludeNs,
+			Selector: nsSelector,
+			Scoped:   namespaced,
+		},
+		PodSelector: podSelector,
+	}
+}
 
-	// parse CLI params
-	initFlags()
-	flag.Parse()
+// loadConfig returns the rules from the config file or builds a single rule from cli params
+// Namespace and Pod filters from cli params are used when the config file does not set them
+func loadConfig() (*config.Config, error) {
+	if configFile != "" {
+		cfg, err := config.Read(configFile)
+		if err != nil {
+			return nil, err
+		}
+		if reflect.DeepEqual(cfg.PodFilter, k8s.PodFilter{}) {
+			cfg.PodFilter = podFilter()
+		}
+		if checkNames != nil {
+			cfg.Checks = checkNames
+		}
+		if minAvailable != "" {
+			cfg.MinAvailable = minAvailableValue()
+		}
+		cfg.Clusters = append(cfg.Clusters, clusters()...)
+		cfg.Notifiers = append(cfg.Notifiers, notifiers()...)
+		return cfg, cfg.Validate()
+	}
+
+	rule := config.Rule{
+		Name:    "default",
+		Reason:  eventReason,
+		Message: errorMessage,
+		ActionSpec: k8s.ActionSpec{
+			Action:      actionName,
+			Labels:      podLabels,
+			Annotations: podAnnotations,
+			WebhookURL:  webhookURL,
+		},
+	}
+	if gracePeriod >= 0 {
+		rule.GracePeriodSeconds = &gracePeriod
+	}
+	if propagation != "" {
+		policy := metav1.DeletionPropagation(propagation)
+		rule.PropagationPolicy = &policy
+	}
+	cfg := &config.Config{
+		PodFilter: podFilter(),
+		Checks:    checkNames,
+		Rules:     []config.Rule{rule},
+		Notifiers: notifiers(),
+		Clusters:  clusters(),
+	}
+	if minAvailable != "" {
+		cfg.MinAvailable = minAvailableValue()
+	}
+	return cfg, cfg.Validate()
+}
+
+// minAvailableValue returns --min-available as a number, or a percentage when it ends with %
+func minAvailableValue() *intstr.IntOrString {
+	v := intstr.Parse(minAvailable)
+	return &v
+}
 
-	// we use this counter in first iteration where we look at all Events in the cluster
-	// if counter > 0 we filter out events older than polling interval
-	counter := 0
+// notifiers returns the webhooks set with --notify-urls
+func notifiers() []notify.Webhook {
+	var webhooks []notify.Webhook
+	for _, u := range notifyURLs {
+		webhooks = append(webhooks, notify.Webhook{URL: u, Format: notifyFormat, Secret: notifySecret})
+	}
+	return webhooks
+}
 
-	for {
-		log.Printf("Running every %d seconds", pollingInterval)
+// usage prints the subcommands and flags
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, `Usage: %s [command] [flags]
 
-		// authenticate to k8s cluster and initialise k8s client
-		c, err := k8s.NewK8sClient(*kubeconfig)
-		if err != nil {
-			log.Println(err)
-			os.Exit(1)
+Commands:
+  run                        restart failing Pods every polling interval (default)
+  once                       run a single scan and remediation cycle and exit
+  scan                       report the failing Pods and the verdict of their checks without acting (see --output)
+  explain <namespace>/<pod>  show why a Pod would or would not be restarted
+  simulate <file>...         replay recorded Events and Pods (eg: kubectl get events,pods -A -o json) offline
+                             and report what would have been restarted, and when (see --output)
+  verify-audit <file>...     verify the hash chain of audit log files, given from the oldest to the most recent
+  preflight                  review with SelfSubjectAccessReviews the permissions the rules and actions need,
+                             and report the missing ones (see --output)
+
+Exit codes:
+  0  every failing Pod was remediated or skipped
+  1  invalid configuration, Pods could not be listed, or permissions are missing (preflight)
+  2  unknown command or invalid arguments
+  3  some failing Pods could not be checked or remediated
+
+Flags:
+`, filepath.Base(os.Args[0]))
+	flag.PrintDefaults()
+}
+
+// parseArgs parses the cli params and returns the arguments (eg: the explain target) found before, between or after them
+func parseArgs(args []string) []string {
+	var positional []string
+	for len(args) > 0 {
+		if !strings.HasPrefix(args[0], "-") {
+			positional, args = append(positional, args[0]), args[1:]
+			continue
 		}
+		flag.CommandLine.Parse(args)
+		args = flag.Args()
+	}
+	return positional
+}
 
-		// generate a unique list of Pods that match Event Reason
-		// we do this because a Pod might have multiple Events with the same Reason
-		uniquePodList, err := c.GenerateToBeDeletedPodList(ctx, namespace, eventReason, errorMessage, counter, pollingInterval)
-		if err != nil {
-			log.Println(err)
+func main() {
+	os.Exit(execute(os.Args[1:]))
+}
+
+// execute parses the subcommand and cli params, runs the subcommand and returns the exit code
+func execute(args []string) int {
+
+	// parse subcommand and CLI params
+	initFlags()
+	flag.Usage = usage
+	command := commandRun
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+	positional := parseArgs(args)
+	// the secrets are read from the environment after parsing, so that usage never prints them as the default value
+	if notifySecret == "" {
+		notifySecret = os.Getenv("POD_RESTARTER_NOTIFY_SECRET")
+	}
+	if auditKey == "" {
+		auditKey = os.Getenv("POD_RESTARTER_AUDIT_KEY")
+	}
+
+	switch preflightMode {
+	case preflightWarn, preflightFail, preflightOff:
+	default:
+		fmt.Fprintf(flag.CommandLine.Output(), "invalid --preflight %q: expected %s, %s or %s\n", preflightMode, preflightWarn, preflightFail, preflightOff)
+		return exitUsage
+	}
+
+	switch command {
+	case commandRun:
+		if livenessCycles < 1 {
+			fmt.Fprintf(flag.CommandLine.Output(), "invalid --liveness-intervals %d: must be at least 1\n", livenessCycles)
+			return exitUsage
+		}
+	case commandOnce:
+	case commandScan, commandSimulate, commandPreflight:
+		if err := reconciler.ValidateOutput(outputFormat); err != nil {
+			fmt.Fprintln(flag.CommandLine.Output(), err)
+			return exitUsage
+		}
+		if command == commandSimulate && len(positional) == 0 {
+			fmt.Fprintln(flag.CommandLine.Output(), "simulate requires recorded Events and Pods: simulate <file>...")
+			return exitUsage
+		}
+	case commandVerify:
+		if len(positional) == 0 {
+			fmt.Fprintln(flag.CommandLine.Output(), "verify-audit requires audit log files: verify-audit <file>...")
+			return exitUsage
+		}
+	case commandExplain:
+		if len(positional) != 1 {
+			fmt.Fprintln(flag.CommandLine.Output(), "explain requires a Pod: explain <namespace>/<pod>")
+			return exitUsage
 		}
+	default:
+		fmt.Fprintf(flag.CommandLine.Output(), "unknown command %q\n", command)
+		usage()
+		return exitUsage
+	}
+
+	logger, err := logging.New(os.Stderr, logFormat, logLevel)
+	if err != nil {
+		slog.Error("Invalid logging configuration", logging.Err(err))
+		return exitError
+	}
+	slog.SetDefault(logger)
+
+	// audit logs are verified offline and without config
+	if command == commandVerify {
+		return verifyAuditCommand(os.Stdout, positional, []byte(auditKey))
+	}
 
-		// allow Pending Pods a few seconds to self heal
-		time.Sleep(healTime * time.Second)
+	cfg, err := loadConfig()
+	if err != nil {
+		slog.Error("Invalid configuration", logging.Err(err))
+		return exitError
+	}
+
+	// stop gracefully on SIGINT and SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// simulations replay recorded objects and never talk to a cluster
+	if command == commandSimulate {
+		if len(cfg.Clusters) > 0 {
+			slog.Error("Invalid configuration", logging.Err(errors.New("simulate does not support multi-cluster mode")))
+			return exitError
+		}
+		return simulateCommand(ctx, cfg, os.Stdout, outputFormat, positional)
+	}
 
-		// iterate through the list of Pods that match Event Reason
-		for pod, ns := range uniquePodList {
+	clk := clock.RealClock{}
 
-			err = c.PodChecks(ctx, pod, ns)
-			if err != nil {
-				log.Println(err)
-				continue
-			}
+	// only the run command lives long enough to be probed
+	interval := time.Duration(pollingInterval) * time.Second
+	if interval <= 0 {
+		interval = reconciler.DefaultPollingInterval
+	}
+	var status *health.Status
+	if command == commandRun && healthAddr != "" {
+		status = health.NewStatus(clk, time.Duration(livenessCycles)*interval)
+	}
 
-			if dryRunMode {
-				log.Printf("[DRY-RUN]: Would have deleted Pod: %s/%s", ns, pod)
-				continue
-			}
-			// delete Pod
-			err := c.DeletePod(ctx, pod, ns)
-			if err != nil {
-				log.Println(err)
-			}
+	notifier, err := notify.New(cfg.Notifiers, clk)
+	if err != nil {
+		slog.Error("Invalid configuration", logging.Err(err))
+		return exitError
+	}
 
+	// only run and once make decisions worth auditing
+	var auditor *audit.Log
+	if (command == commandRun || command == commandOnce) && auditLog != "" {
+		auditor, err = audit.Open(auditLog, auditMaxSize*1024*1024, auditBackups, []byte(auditKey))
+		if err != nil {
+			slog.Error("Could not open audit log", logging.Err(err))
+			return exitError
 		}
-		time.Sleep(time.Duration(pollingInterval-int(healTime)) * time.Second) // sleep for n seconds
-		counter += 1
+		defer auditor.Close()
+	}
+
+	// authenticate to every k8s cluster, initialise its k8s client once for the process lifetime
+	// and resolve the check pipeline and the action of every rule
+	rs, err := newReconcilers(cfg, clk, k8s.ClientOptions{
+		Kubeconfig: *kubeconfig,
+		PageSize:   pageSize,
+		QPS:        float32(kubeAPIQPS),
+		Burst:      kubeAPIBurst,
+		UserAgent:  userAgent,
+		Timeout:    requestTimeout,
+		Clock:      clk,
+	}, reconciler.Options{
+		DryRun:          dryRunMode,
+		HealTime:        reconciler.DefaultHealTime,
+		PollingInterval: interval,
+		Lookback:        lookback,
+		Health:          status,
+		Notifier:        notifier,
+		Audit:           auditor,
+		Paused:          paused,
+		PauseConfigMap:  pauseConfigMap,
+		Workers:         workers,
+		MaxRemediations: maxRemediations,
+	})
+	if err != nil {
+		slog.Error("Could not start", logging.Err(err))
+		return exitError
+	}
+
+	if (command == commandRun || command == commandOnce) && !startupPreflight(ctx, rs, preflightMode) {
+		return exitError
+	}
+
+	switch command {
+	case commandOnce:
+		return onceCommand(ctx, rs)
+	case commandScan:
+		return scanCommand(ctx, rs, os.Stdout, outputFormat)
+	case commandPreflight:
+		return preflightCommand(ctx, rs, os.Stdout, outputFormat)
+	case commandExplain:
+		return explainCommand(ctx, rs, os.Stdout, positional[0])
+	default:
+		return runCommand(ctx, rs, status, healthAddr)
 	}
 }
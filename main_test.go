@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andreistefanciprian/pod-restarter-go/audit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestLoadConfigOverrides(t *testing.T) {
+	tests := map[string]struct {
+		content        string
+		checks         stringList
+		expectedChecks [][]string
+	}{
+		"Config file checks": {
+			content: `
+checks: [has-owner, unhealthy]
+rules:
+  - reason: BackOff
+`,
+			expectedChecks: [][]string{{"has-owner", "unhealthy"}},
+		},
+		"--checks replaces the default checks of the rules": {
+			content: `
+rules:
+  - reason: BackOff
+  - reason: Failed
+    checks: [unhealthy]
+`,
+			checks:         stringList{"has-owner"},
+			expectedChecks: [][]string{{"has-owner"}, {"unhealthy"}},
+		},
+		"--checks replaces the config file checks": {
+			content: `
+checks: [has-owner, unhealthy]
+rules:
+  - reason: BackOff
+`,
+			checks:         stringList{"not-terminating"},
+			expectedChecks: [][]string{{"not-terminating"}},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(tc.content), 0600))
+			configFile, checkNames = path, tc.checks
+			t.Cleanup(func() { configFile, checkNames = "", nil })
+
+			cfg, err := loadConfig()
+			require.NoError(t, err)
+			require.Len(t, cfg.Rules, len(tc.expectedChecks))
+			for i, rule := range cfg.Rules {
+				assert.Equal(t, tc.expectedChecks[i], rule.Checks, rule.Name)
+			}
+		})
+	}
+}
+
+func TestLoadConfigMinAvailable(t *testing.T) {
+	tests := map[string]struct {
+		content      string
+		minAvailable string
+		expected     []*intstr.IntOrString
+	}{
+		"Config file minimum": {
+			content: `
+minAvailable: 1
+rules:
+  - reason: BackOff
+`,
+			expected: []*intstr.IntOrString{{Type: intstr.Int, IntVal: 1}},
+		},
+		"--min-available replaces the config file minimum": {
+			content: `
+minAvailable: 1
+rules:
+  - reason: BackOff
+  - reason: Failed
+    minAvailable: 75%
+`,
+			minAvailable: "50%",
+			expected:     []*intstr.IntOrString{{Type: intstr.String, StrVal: "50%"}, {Type: intstr.String, StrVal: "75%"}},
+		},
+		"--min-available adds the check to every rule": {
+			content: `
+rules:
+  - reason: BackOff
+`,
+			minAvailable: "2",
+			expected:     []*intstr.IntOrString{{Type: intstr.Int, IntVal: 2}},
+		},
+		"Disabled": {
+			content: `
+rules:
+  - reason: BackOff
+`,
+			expected: []*intstr.IntOrString{nil},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(tc.content), 0600))
+			configFile, minAvailable = path, tc.minAvailable
+			t.Cleanup(func() { configFile, minAvailable = "", "" })
+
+			cfg, err := loadConfig()
+			require.NoError(t, err)
+			require.Len(t, cfg.Rules, len(tc.expected))
+			for i, rule := range cfg.Rules {
+				assert.Equal(t, tc.expected[i], rule.MinAvailable, rule.Name)
+			}
+		})
+	}
+}
+
+func TestNotifySecretFromEnv(t *testing.T) {
+	t.Setenv("POD_RESTARTER_NOTIFY_SECRET", "s3cr3t-token")
+	var out bytes.Buffer
+	flag.CommandLine.SetOutput(&out)
+	t.Cleanup(func() {
+		flag.CommandLine.SetOutput(nil)
+		notifySecret = ""
+	})
+
+	// usage is printed for unknown commands, like for -h
+	code := execute([]string{"unknown"})
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, out.String(), "-notify-secret")
+	assert.NotContains(t, out.String(), "s3cr3t-token")
+	assert.Equal(t, "s3cr3t-token", notifySecret)
+}
+
+func TestVerifyAuditCommandAfterRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	key := []byte("s3cr3t")
+
+	// every file holds a record, rotation removed the 3 oldest records
+	l, err := audit.Open(path, 1, 2, key)
+	require.NoError(t, err)
+	for i := 0; i < 6; i++ {
+		require.NoError(t, l.Write(audit.Record{Pod: fmt.Sprintf("pod-%d", i), Decision: audit.DecisionSkip}))
+	}
+	require.NoError(t, l.Close())
+	_, err = os.Stat(path + ".3")
+	require.ErrorIs(t, err, os.ErrNotExist)
+	files := []string{path + ".2", path + ".1", path}
+
+	var out bytes.Buffer
+	assert.Equal(t, exitOK, verifyAuditCommand(&out, files, key))
+	assert.Contains(t, out.String(), "OK: the hash chain of 3 file(s) is intact")
+
+	assert.Equal(t, exitError, verifyAuditCommand(&out, files, []byte("other")))
+	assert.Equal(t, exitError, verifyAuditCommand(&out, []string{path + ".2", path}, key), "a file missing from the chain")
+}
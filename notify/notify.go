@@ -0,0 +1,294 @@
+// Package notify POSTs the outcome of remediations to webhooks, once per cycle
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andreistefanciprian/pod-restarter-go/logging"
+	"k8s.io/utils/clock"
+)
+
+// Payload formats
+const (
+	FormatGeneric = "generic"
+	FormatSlack   = "slack"
+)
+
+// SignatureHeader holds the hex encoded HMAC-SHA256 of the body, prefixed with "sha256=", when a webhook has a secret
+const SignatureHeader = "X-Pod-Restarter-Signature"
+
+// DefaultRetries is the number of times a failed POST is retried
+const DefaultRetries = 3
+
+// DefaultTimeout bounds the time Send spends notifying the webhooks of a cycle, retries included,
+// so that unreachable webhooks do not hold up the next cycle
+const DefaultTimeout = 15 * time.Second
+
+// Webhook is an endpoint notified of remediation outcomes
+// Format is generic (default) or slack; the body is signed with Secret when it is set
+// Retries overrides DefaultRetries
+type Webhook struct {
+	URL     string `json:"url"`
+	Format  string `json:"format,omitempty"`
+	Secret  string `json:"secret,omitempty"`
+	Retries *int   `json:"retries,omitempty"`
+}
+
+// Notification is the outcome of the remediation of a Pod
+// Message is the message of the latest Event that matched the rule
+type Notification struct {
+	Time      time.Time `json:"time"`
+	Cluster   string    `json:"cluster,omitempty"`
+	Namespace string    `json:"namespace"`
+	Pod       string    `json:"pod"`
+	Owner     string    `json:"owner,omitempty"`
+	Node      string    `json:"node,omitempty"`
+	Rule      string    `json:"rule"`
+	Message   string    `json:"message,omitempty"`
+	Action    string    `json:"action"`
+	Outcome   string    `json:"outcome"`
+	DryRun    bool      `json:"dryRun"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Batch is the body POSTed to generic webhooks
+type Batch struct {
+	Time          time.Time      `json:"time"`
+	Notifications []Notification `json:"notifications"`
+}
+
+// SlackMessage is the body POSTed to slack webhooks
+type SlackMessage struct {
+	Text string `json:"text"`
+}
+
+// Validate sets the default format and returns error if the webhook is invalid
+func (w *Webhook) Validate() error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("notifier requires an http(s) url: %q", w.URL)
+	}
+	if w.Format == "" {
+		w.Format = FormatGeneric
+	}
+	if w.Format != FormatGeneric && w.Format != FormatSlack {
+		return fmt.Errorf("notifier %s: invalid format %q: expected %s or %s", u.Host, w.Format, FormatGeneric, FormatSlack)
+	}
+	if w.Retries != nil && *w.Retries < 0 {
+		return fmt.Errorf("notifier %s: retries must not be negative", u.Host)
+	}
+	return nil
+}
+
+// Notifier sends the notifications of a cycle to every webhook in one batch
+// A nil Notifier drops the notifications
+type Notifier struct {
+	webhooks []Webhook
+	client   *http.Client
+	clock    clock.Clock
+	backoff  time.Duration
+	timeout  time.Duration
+}
+
+// New returns a Notifier for webhooks, or nil when there are none
+// Retries are spaced on clk, starting at one second and doubling after every attempt
+func New(webhooks []Webhook, clk clock.Clock) (*Notifier, error) {
+	if len(webhooks) == 0 {
+		return nil, nil
+	}
+	for i := range webhooks {
+		err := webhooks[i].Validate()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &Notifier{
+		webhooks: webhooks,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		clock:    clk,
+		backoff:  time.Second,
+		timeout:  DefaultTimeout,
+	}, nil
+}
+
+// Send POSTs notifications to every webhook at the same time, and gives up on the webhooks still failing after DefaultTimeout
+// The returned error joins the errors of the webhooks that could not be notified after all retries
+func (n *Notifier) Send(ctx context.Context, notifications []Notification) error {
+	if n == nil || len(notifications) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, n.timeout)
+	defer cancel()
+
+	errs := make([]error, len(n.webhooks))
+	var wg sync.WaitGroup
+	for i, w := range n.webhooks {
+		wg.Add(1)
+		go func(i int, w Webhook) {
+			defer wg.Done()
+			body, err := encode(w.Format, n.clock.Now().UTC(), notifications)
+			if err == nil {
+				err = n.post(ctx, w, body)
+			}
+			errs[i] = err
+		}(i, w)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// post POSTs body to the webhook, retrying on network errors, 429 and 5xx responses
+// Retries wait for the Retry-After of the response, or back off exponentially; a retry past the deadline of ctx is not attempted
+func (n *Notifier) post(ctx context.Context, w Webhook, body []byte) error {
+	retries := DefaultRetries
+	if w.Retries != nil {
+		retries = *w.Retries
+	}
+	backoff := n.backoff
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		var retryAfter time.Duration
+		retry, retryAfter, err = n.postOnce(ctx, w, body)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("Could not notify %s: %w", redact(w.URL), ctx.Err())
+		}
+		if !retry || attempt >= retries {
+			break
+		}
+		wait := backoff
+		if retryAfter > 0 {
+			wait = retryAfter
+		}
+		if deadline, ok := ctx.Deadline(); ok && wait > time.Until(deadline) {
+			return fmt.Errorf("Could not notify %s: %w, retrying after %s would pass the notification deadline", redact(w.URL), err, wait)
+		}
+		slog.Debug("Retrying notification", "url", redact(w.URL), "attempt", attempt+1, "wait", wait, logging.Err(err))
+
+		timer := n.clock.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("Could not notify %s: %w", redact(w.URL), ctx.Err())
+		case <-timer.C():
+		}
+		backoff *= 2
+	}
+	return fmt.Errorf("Could not notify %s: %w", redact(w.URL), err)
+}
+
+// postOnce POSTs body to the webhook and returns whether a failed request may be retried, and after how long
+// when the response says so with Retry-After (0 otherwise)
+func (n *Notifier) postOnce(ctx context.Context, w Webhook, body []byte) (bool, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(w.Secret, body))
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		// the url.Error would leak the webhook URL into the logs
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return ctx.Err() == nil, 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return false, 0, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, n.retryAfter(resp.Header.Get("Retry-After")), fmt.Errorf("webhook returned %s", resp.Status)
+}
+
+// retryAfter returns the wait of a Retry-After header, in seconds or as an HTTP date, or 0 when there is none
+func (n *Notifier) retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(n.clock.Now()) {
+		return t.Sub(n.clock.Now())
+	}
+	return 0
+}
+
+// Sign returns the signature of body sent in SignatureHeader
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// encode returns the body of the notifications in the webhook format
+func encode(format string, now time.Time, notifications []Notification) ([]byte, error) {
+	if format == FormatSlack {
+		return json.Marshal(SlackMessage{Text: slackText(notifications)})
+	}
+	return json.Marshal(Batch{Time: now, Notifications: notifications})
+}
+
+// slackText summarizes the notifications in Slack mrkdwn, one line per Pod
+func slackText(notifications []Notification) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "pod-restarter remediation outcomes for %d Pod(s)", len(notifications))
+	for _, n := range notifications {
+		b.WriteString("\n• ")
+		if n.Cluster != "" {
+			fmt.Fprintf(&b, "[%s] ", n.Cluster)
+		}
+		fmt.Fprintf(&b, "`%s/%s` rule *%s*: %s %s", n.Namespace, n.Pod, n.Rule, n.Action, n.Outcome)
+		if n.DryRun {
+			b.WriteString(" (dry run)")
+		}
+		if n.Owner != "" {
+			fmt.Fprintf(&b, ", owner %s", n.Owner)
+		}
+		if n.Node != "" {
+			fmt.Fprintf(&b, ", node %s", n.Node)
+		}
+		if n.Error != "" {
+			fmt.Fprintf(&b, ", error: %s", n.Error)
+		}
+		if n.Message != "" {
+			fmt.Fprintf(&b, "\n> %s", n.Message)
+		}
+	}
+	return b.String()
+}
+
+// redact returns the webhook URL without its path and query, which often hold a token (eg: Slack incoming webhooks)
+func redact(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "webhook"
+	}
+	return u.Scheme + "://" + u.Host
+}
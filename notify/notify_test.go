@@ -0,0 +1,320 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/utils/clock"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+var testNow = time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+
+func testNotifications() []Notification {
+	return []Notification{
+		{
+			Time:      testNow,
+			Namespace: "default",
+			Pod:       "foo",
+			Owner:     "ReplicaSet/foo-rs",
+			Node:      "node-1",
+			Rule:      "veth",
+			Message:   "container veth name provided (eth0) already exists",
+			Action:    "delete",
+			Outcome:   "deleted",
+		},
+		{
+			Time:      testNow,
+			Namespace: "default",
+			Pod:       "bar",
+			Rule:      "veth",
+			Action:    "delete",
+			Outcome:   "failed",
+			Error:     "forbidden",
+		},
+	}
+}
+
+// request is a request received by the test webhook
+type request struct {
+	header http.Header
+	body   []byte
+}
+
+// testWebhook returns a server that answers with statuses in turn (200 once they are used up) and records the requests
+func testWebhook(t *testing.T, statuses ...int) (*httptest.Server, func() []request) {
+	var mu sync.Mutex
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, request{header: r.Header, body: body})
+		if len(requests) <= len(statuses) {
+			w.WriteHeader(statuses[len(requests)-1])
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []request {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+}
+
+func newTestNotifier(t *testing.T, clk clock.Clock, webhooks ...Webhook) *Notifier {
+	n, err := New(webhooks, clk)
+	require.NoError(t, err)
+	n.backoff = time.Millisecond
+	return n
+}
+
+func TestSendGeneric(t *testing.T) {
+	server, requests := testWebhook(t)
+	n := newTestNotifier(t, testingclock.NewFakeClock(testNow), Webhook{URL: server.URL + "/hooks/restarts", Secret: "s3cr3t"})
+
+	require.NoError(t, n.Send(context.Background(), testNotifications()))
+
+	received := requests()
+	require.Len(t, received, 1, "Expected one batch per cycle")
+	assert.Equal(t, "application/json", received[0].header.Get("Content-Type"))
+	assert.Equal(t, Sign("s3cr3t", received[0].body), received[0].header.Get(SignatureHeader))
+
+	var batch Batch
+	require.NoError(t, json.Unmarshal(received[0].body, &batch))
+	assert.Equal(t, testNow, batch.Time)
+	assert.Equal(t, testNotifications(), batch.Notifications)
+}
+
+func TestSendSlack(t *testing.T) {
+	server, requests := testWebhook(t)
+	n := newTestNotifier(t, testingclock.NewFakeClock(testNow), Webhook{URL: server.URL, Format: FormatSlack})
+
+	require.NoError(t, n.Send(context.Background(), testNotifications()))
+
+	received := requests()
+	require.Len(t, received, 1)
+	assert.Empty(t, received[0].header.Get(SignatureHeader), "Expected unsigned payload without secret")
+
+	var msg SlackMessage
+	require.NoError(t, json.Unmarshal(received[0].body, &msg))
+	assert.Equal(t, "pod-restarter remediation outcomes for 2 Pod(s)"+
+		"\n• `default/foo` rule *veth*: delete deleted, owner ReplicaSet/foo-rs, node node-1"+
+		"\n> container veth name provided (eth0) already exists"+
+		"\n• `default/bar` rule *veth*: delete failed, error: forbidden", msg.Text)
+}
+
+func TestSendRetries(t *testing.T) {
+	retries := 1
+	testCases := []struct {
+		testName         string
+		statuses         []int
+		retries          *int
+		expectedRequests int
+		expectError      bool
+	}{
+		{
+			testName:         "Retry server errors",
+			statuses:         []int{http.StatusInternalServerError, http.StatusBadGateway},
+			expectedRequests: 3,
+		},
+		{
+			testName:         "Retry rate limited requests",
+			statuses:         []int{http.StatusTooManyRequests},
+			expectedRequests: 2,
+		},
+		{
+			testName:         "Do not retry client errors",
+			statuses:         []int{http.StatusBadRequest},
+			expectedRequests: 1,
+			expectError:      true,
+		},
+		{
+			testName:         "Give up after the retries",
+			statuses:         []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+			retries:          &retries,
+			expectedRequests: 2,
+			expectError:      true,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			server, requests := testWebhook(t, test.statuses...)
+			n := newTestNotifier(t, clock.RealClock{}, Webhook{URL: server.URL + "/token", Retries: test.retries})
+
+			err := n.Send(context.Background(), testNotifications())
+			if test.expectError {
+				require.Error(t, err)
+				assert.NotContains(t, err.Error(), "/token", "Expected the webhook URL to be redacted")
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Len(t, requests(), test.expectedRequests)
+		})
+	}
+}
+
+func TestSendBackoff(t *testing.T) {
+	server, requests := testWebhook(t, http.StatusInternalServerError)
+	clk := testingclock.NewFakeClock(testNow)
+	n := newTestNotifier(t, clk, Webhook{URL: server.URL})
+	n.backoff, n.timeout = time.Minute, time.Hour
+
+	done := make(chan error)
+	go func() {
+		done <- n.Send(context.Background(), testNotifications())
+	}()
+
+	// the retry waits for the backoff on the clock
+	require.Eventually(t, clk.HasWaiters, 5*time.Second, time.Millisecond)
+	assert.Len(t, requests(), 1)
+	clk.Step(time.Minute)
+	require.NoError(t, <-done)
+	assert.Len(t, requests(), 2)
+}
+
+func TestSendRetryAfter(t *testing.T) {
+	retryAfter := func(value string) http.HandlerFunc {
+		var calls int
+		return func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if calls == 1 {
+				w.Header().Set("Retry-After", value)
+				w.WriteHeader(http.StatusTooManyRequests)
+			}
+		}
+	}
+	testCases := []struct {
+		testName     string
+		retryAfter   string
+		expectedWait time.Duration
+	}{
+		{testName: "Seconds", retryAfter: "120", expectedWait: 2 * time.Minute},
+		{testName: "HTTP date", retryAfter: testNow.Add(90 * time.Second).Format(http.TimeFormat), expectedWait: 90 * time.Second},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			server := httptest.NewServer(retryAfter(test.retryAfter))
+			t.Cleanup(server.Close)
+			clk := testingclock.NewFakeClock(testNow)
+			n := newTestNotifier(t, clk, Webhook{URL: server.URL})
+			n.timeout = time.Hour
+
+			done := make(chan error)
+			go func() {
+				done <- n.Send(context.Background(), testNotifications())
+			}()
+
+			// the retry waits for Retry-After instead of the backoff
+			require.Eventually(t, clk.HasWaiters, 5*time.Second, time.Millisecond)
+			clk.Step(test.expectedWait - time.Second)
+			select {
+			case err := <-done:
+				t.Fatalf("retried before Retry-After: %v", err)
+			case <-time.After(50 * time.Millisecond):
+			}
+			clk.Step(time.Second)
+			require.NoError(t, <-done)
+		})
+	}
+}
+
+func TestSendDeadline(t *testing.T) {
+	// a Retry-After past the deadline is not waited for
+	server, requests := testWebhook(t, http.StatusTooManyRequests)
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	t.Cleanup(slow.Close)
+	n := newTestNotifier(t, clock.RealClock{}, Webhook{URL: slow.URL}, Webhook{URL: server.URL})
+	start := time.Now()
+	err := n.Send(context.Background(), testNotifications())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "deadline")
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.Len(t, requests(), 2, "Expected the other webhook to be notified")
+
+	// webhooks that do not answer are given up on at the deadline, while the others are notified at the same time
+	release := make(chan struct{})
+	hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	t.Cleanup(hanging.Close)
+	t.Cleanup(func() { close(release) })
+	server, requests = testWebhook(t)
+	n = newTestNotifier(t, clock.RealClock{}, Webhook{URL: hanging.URL}, Webhook{URL: server.URL})
+	n.timeout = 100 * time.Millisecond
+	start = time.Now()
+	err = n.Send(context.Background(), testNotifications())
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.Len(t, requests(), 1)
+}
+
+func TestWebhookValidate(t *testing.T) {
+	negative := -1
+	testCases := []struct {
+		testName       string
+		webhook        Webhook
+		expectedFormat string
+		expectError    bool
+	}{
+		{
+			testName:       "Default format",
+			webhook:        Webhook{URL: "https://hooks.example.com/restarts"},
+			expectedFormat: FormatGeneric,
+		},
+		{
+			testName:       "Slack format",
+			webhook:        Webhook{URL: "https://hooks.slack.com/services/T0/B0/X", Format: FormatSlack},
+			expectedFormat: FormatSlack,
+		},
+		{
+			testName:    "Reject URL without scheme",
+			webhook:     Webhook{URL: "hooks.example.com"},
+			expectError: true,
+		},
+		{
+			testName:    "Reject unknown format",
+			webhook:     Webhook{URL: "https://hooks.example.com", Format: "teams"},
+			expectError: true,
+		},
+		{
+			testName:    "Reject negative retries",
+			webhook:     Webhook{URL: "https://hooks.example.com", Retries: &negative},
+			expectError: true,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			err := test.webhook.Validate()
+			if test.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedFormat, test.webhook.Format)
+		})
+	}
+}
+
+func TestNilNotifier(t *testing.T) {
+	n, err := New(nil, clock.RealClock{})
+	require.NoError(t, err)
+	assert.Nil(t, n)
+	assert.NoError(t, n.Send(context.Background(), testNotifications()))
+}
@@ -0,0 +1,97 @@
+package reconciler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	k8s "github.com/andreistefanciprian/pod-restarter-go/kubernetes"
+)
+
+// Explain writes the Pod details, and for every rule the matched Events, the result of every check and the verdict
+func (r *Reconciler) Explain(ctx context.Context, w io.Writer, namespace, pod string) error {
+	events, err := r.client.PodEvents(ctx, pod, namespace)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	defer tw.Flush()
+	if r.opts.Cluster != "" {
+		fmt.Fprintf(tw, "Cluster:\t%s\n", r.opts.Cluster)
+	}
+	fmt.Fprintf(tw, "Pod:\t%s/%s\n", namespace, pod)
+
+	for i, rule := range r.cfg.Rules {
+		verdict, err := r.client.PodChecks(ctx, pod, namespace, r.pipelines[i])
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			if verdict.Pod == nil {
+				fmt.Fprintf(tw, "Status:\tdoes not exist\n")
+			} else {
+				fmt.Fprintf(tw, "UID:\t%s\n", verdict.Pod.UID)
+				fmt.Fprintf(tw, "Node:\t%s\n", verdict.Pod.NodeName)
+				fmt.Fprintf(tw, "Phase:\t%s\n", verdict.Pod.Phase)
+				fmt.Fprintf(tw, "Owner:\t%s\n", verdict.Pod.Owner())
+			}
+		}
+
+		fmt.Fprintf(tw, "\nRule %s:\treason %s, message %q\n", rule.Name, rule.Reason, rule.Message)
+		fmt.Fprintf(tw, "  Matched Events:\n")
+		var matched int
+		for _, event := range events {
+			if event.Matches(rule.Reason, rule.Message) {
+				fmt.Fprintf(tw, "    %s\t%s\t%s\n", event.LastTimestamp.Format(time.RFC3339), event.Reason, event.Message)
+				matched++
+			}
+		}
+		if matched == 0 {
+			fmt.Fprintf(tw, "    <none>\n")
+		}
+		fmt.Fprintf(tw, "  Checks:\n")
+		for _, check := range verdict.Checks {
+			reason := ""
+			if check.Reason != nil {
+				reason = check.Reason.Error()
+			}
+			fmt.Fprintf(tw, "    %s\t%s\t%s\n", check.Name, check.Outcome, reason)
+		}
+
+		explanation, err := r.explainVerdict(ctx, i, verdict, matched)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(tw, "  Verdict:\t%s\n", explanation)
+	}
+	return nil
+}
+
+// explainVerdict returns whether the rule at index i would restart the Pod and why
+func (r *Reconciler) explainVerdict(ctx context.Context, i int, verdict *k8s.Verdict, matched int) (string, error) {
+	if matched == 0 {
+		return "would not restart: no Event matches the rule", nil
+	}
+	if !verdict.Restart {
+		return fmt.Sprintf("would not restart: %v", verdict.Reason), nil
+	}
+	selected, err := r.client.MatchesFilter(ctx, r.cfg.PodFilter, verdict.Pod)
+	if err != nil {
+		return "", err
+	}
+	if !selected {
+		return "would not restart: Pod is not selected by the namespace and Pod filters", nil
+	}
+	// plan the action without executing it
+	result, err := r.client.Remediate(ctx, r.actions[i], verdict.Pod, true)
+	if err != nil {
+		return fmt.Sprintf("would not restart: %s action cannot be planned: %v", r.actions[i].Name(), err), nil
+	}
+	if deferral := r.deferral(r.pause(ctx), i); deferral != nil {
+		return fmt.Sprintf("would not restart now: %v, then would %s", deferral, result.Plan.Description), nil
+	}
+	return "would " + result.Plan.Description, nil
+}
@@ -0,0 +1,68 @@
+package reconciler
+
+import (
+	"fmt"
+	"sync"
+)
+
+// DefaultWorkers is the number of candidates checked and remediated at the same time when Options.Workers is not set
+const DefaultWorkers = 4
+
+// parallel calls fn for every index below n on at most workers goroutines, and waits for all the calls to return
+// A panic in fn is recovered and returned at its index, so it never stops the calls of the other indexes
+func parallel(n, workers int, fn func(i int)) []error {
+	panics := make([]error, n)
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, n); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				panics[i] = call(i, fn)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return panics
+}
+
+// budget counts the remediations of a cycle shared by the workers, up to max (unlimited when zero)
+type budget struct {
+	mu   sync.Mutex
+	max  int
+	used int
+}
+
+// reset forgets the remediations of the previous cycle
+func (b *budget) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used = 0
+}
+
+// take reserves a remediation, or returns false once the cycle has used up the budget
+func (b *budget) take() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.max > 0 && b.used >= b.max {
+		return false
+	}
+	b.used++
+	return true
+}
+
+// call calls fn for index i and returns the panic it recovered from, if any
+func call(i int, fn func(i int)) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	fn(i)
+	return nil
+}
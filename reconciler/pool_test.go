@@ -0,0 +1,39 @@
+package reconciler
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParallel(t *testing.T) {
+	var running, maxRunning atomic.Int32
+	done := make([]bool, 10)
+	panics := parallel(len(done), 3, func(i int) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for m := maxRunning.Load(); n > m && !maxRunning.CompareAndSwap(m, n); m = maxRunning.Load() {
+		}
+		time.Sleep(5 * time.Millisecond)
+		if i == 4 {
+			panic("boom")
+		}
+		done[i] = true
+	})
+
+	assert.LessOrEqual(t, maxRunning.Load(), int32(3))
+	assert.Greater(t, maxRunning.Load(), int32(1))
+	for i := range done {
+		if i == 4 {
+			assert.False(t, done[i])
+			assert.EqualError(t, panics[i], "panic: boom")
+			continue
+		}
+		assert.True(t, done[i], i)
+		assert.NoError(t, panics[i], i)
+	}
+
+	assert.Empty(t, parallel(0, 3, func(int) { t.Fatal("called without indexes") }))
+}
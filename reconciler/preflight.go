@@ -0,0 +1,129 @@
+package reconciler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	k8s "github.com/andreistefanciprian/pod-restarter-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// Preflight is the document written by the preflight command: the number of permissions reviewed and the missing ones
+type Preflight struct {
+	Reviewed int                 `json:"reviewed"`
+	Missing  []MissingPermission `json:"missing"`
+}
+
+// MissingPermission is a permission the credentials of a cluster lack
+type MissingPermission struct {
+	Cluster          string `json:"cluster,omitempty"`
+	k8s.AccessReview `json:",inline"`
+}
+
+// Permissions returns every permission the reconciler needs, once: listing Events and Pods in its namespaces,
+// running the checks and the action of every rule there, and reading the pause ConfigMap unless a PauseClient reads it
+func (r *Reconciler) Permissions() []k8s.Permission {
+	permissions := k8s.ScopePermissions(r.cfg.PodFilter)
+	for _, namespace := range k8s.ScopeNamespaces(r.cfg.PodFilter.Namespaces) {
+		for i := range r.cfg.Rules {
+			permissions = append(permissions, k8s.RulePermissions(r.pipelines[i], r.actions[i], namespace, r.opts.DryRun)...)
+		}
+	}
+	if r.pauseName != "" && r.opts.PauseClient == nil {
+		permissions = append(permissions, r.pausePermission())
+	}
+
+	seen := make(map[k8s.Permission]bool)
+	unique := permissions[:0]
+	for _, p := range permissions {
+		if !seen[p] {
+			seen[p] = true
+			unique = append(unique, p)
+		}
+	}
+	return unique
+}
+
+// ReviewAccess asks the API server whether the reconciler has all the permissions it needs
+func (r *Reconciler) ReviewAccess(ctx context.Context) ([]k8s.AccessReview, error) {
+	return r.client.ReviewAccess(ctx, r.Permissions())
+}
+
+// ReviewPauseAccess asks the API server of the PauseClient whether the pause ConfigMap can be read
+// It reviews nothing without a PauseClient, since Permissions then include reading the ConfigMap
+func (r *Reconciler) ReviewPauseAccess(ctx context.Context) ([]k8s.AccessReview, error) {
+	if r.pauseName == "" || r.opts.PauseClient == nil {
+		return nil, nil
+	}
+	return r.opts.PauseClient.ReviewAccess(ctx, []k8s.Permission{r.pausePermission()})
+}
+
+// pausePermission is the permission to read the pause ConfigMap
+func (r *Reconciler) pausePermission() k8s.Permission {
+	return k8s.Permission{Verb: "get", Resource: "configmaps", Namespace: r.pauseNamespace, Name: r.pauseName}
+}
+
+// Add adds the reviews of a cluster to the preflight
+func (p *Preflight) Add(cluster string, reviews []k8s.AccessReview) {
+	p.Reviewed += len(reviews)
+	for _, review := range reviews {
+		if !review.Allowed {
+			p.Missing = append(p.Missing, MissingPermission{Cluster: cluster, AccessReview: review})
+		}
+	}
+}
+
+// WritePreflight writes the preflight in the output format
+// The table has a line per missing permission; the CLUSTER column is only written in multi-cluster mode
+func WritePreflight(w io.Writer, format string, doc *Preflight) error {
+	if doc.Missing == nil {
+		doc.Missing = []MissingPermission{}
+	}
+	switch format {
+	case OutputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(doc)
+	case OutputYAML:
+		data, err := yaml.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	case OutputTable:
+		if len(doc.Missing) == 0 {
+			_, err := fmt.Fprintf(w, "All %d permissions are granted\n", doc.Reviewed)
+			return err
+		}
+		var clusters bool
+		for _, m := range doc.Missing {
+			clusters = clusters || m.Cluster != ""
+		}
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		if clusters {
+			fmt.Fprint(tw, "CLUSTER\t")
+		}
+		fmt.Fprintln(tw, "NAMESPACE\tMISSING PERMISSION\tNAME\tREASON")
+		for _, m := range doc.Missing {
+			namespace := m.Namespace
+			if namespace == "" {
+				namespace = "<all>"
+			}
+			if clusters {
+				fmt.Fprintf(tw, "%s\t", m.Cluster)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", namespace, m.Permission, m.Name, m.Reason)
+		}
+		err := tw.Flush()
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%d of %d permissions are missing\n", len(doc.Missing), doc.Reviewed)
+		return err
+	}
+	return ValidateOutput(format)
+}
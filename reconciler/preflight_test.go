@@ -0,0 +1,107 @@
+package reconciler
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/andreistefanciprian/pod-restarter-go/config"
+	k8s "github.com/andreistefanciprian/pod-restarter-go/kubernetes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestPermissions(t *testing.T) {
+	cfg := &config.Config{
+		PodFilter: k8s.PodFilter{Namespaces: k8s.NamespaceFilter{Include: []string{"team-a", "team-b"}, Scoped: true}},
+		Rules: []config.Rule{
+			{Name: "veth", Reason: vethReason},
+			{Name: "image", Reason: "BackOff", ActionSpec: k8s.ActionSpec{Action: k8s.ActionEvict}},
+			{Name: "crash", Reason: "BackOff", ActionSpec: k8s.ActionSpec{Action: k8s.ActionDelete}},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+	clk := testingclock.NewFakeClock(testNow)
+	client := k8s.NewK8sClientFromClientSet(fake.NewSimpleClientset(), 0, clk)
+
+	names := func(permissions []k8s.Permission) []string {
+		var got []string
+		for _, p := range permissions {
+			got = append(got, p.Namespace+": "+p.String())
+		}
+		return got
+	}
+
+	r, err := New(client, cfg, clk, Options{PauseConfigMap: "kube-system/pod-restarter"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"team-a: list events",
+		"team-a: get pods",
+		"team-b: list events",
+		"team-b: get pods",
+		"team-a: delete pods",
+		"team-a: create pods/eviction",
+		"team-b: delete pods",
+		"team-b: create pods/eviction",
+		"kube-system: get configmaps",
+	}, names(r.Permissions()))
+
+	// the pause ConfigMap read by a pause client is reviewed through it
+	pauseClientSet := fake.NewSimpleClientset()
+	r, err = New(client, cfg, clk, Options{PauseConfigMap: "kube-system/pod-restarter", PauseClient: k8s.NewK8sClientFromClientSet(pauseClientSet, 0, clk)})
+	require.NoError(t, err)
+	assert.NotContains(t, names(r.Permissions()), "kube-system: get configmaps")
+	reviews, err := r.ReviewPauseAccess(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, reviews, 1)
+	assert.Equal(t, "get configmaps", reviews[0].Permission.String())
+	assert.Len(t, pauseClientSet.Actions(), 1)
+
+	// dry runs do not change objects
+	r, err = New(client, cfg, clk, Options{DryRun: true})
+	require.NoError(t, err)
+	assert.Len(t, r.Permissions(), 4)
+	reviews, err = r.ReviewPauseAccess(context.TODO())
+	require.NoError(t, err)
+	assert.Empty(t, reviews)
+}
+
+func TestPreflight(t *testing.T) {
+	r, clientSet, _ := newTestReconciler(t, nil, Options{})
+	clientSet.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		review.Status.Allowed = review.Spec.ResourceAttributes.Verb != "delete"
+		return true, review, nil
+	})
+
+	reviews, err := r.ReviewAccess(context.TODO())
+	require.NoError(t, err)
+	doc := &Preflight{}
+	doc.Add("", reviews)
+	assert.Equal(t, 3, doc.Reviewed)
+	require.Len(t, doc.Missing, 1)
+	assert.Equal(t, "delete pods", doc.Missing[0].Permission.String())
+
+	var b bytes.Buffer
+	require.NoError(t, WritePreflight(&b, OutputTable, doc))
+	assert.Equal(t, "NAMESPACE  MISSING PERMISSION  NAME  REASON\n<all>      delete pods               \n1 of 3 permissions are missing\n", b.String())
+
+	b.Reset()
+	doc.Add("prod", reviews)
+	require.NoError(t, WritePreflight(&b, OutputTable, doc))
+	assert.Contains(t, b.String(), "CLUSTER  NAMESPACE")
+	assert.Contains(t, b.String(), "2 of 6 permissions are missing")
+
+	b.Reset()
+	require.NoError(t, WritePreflight(&b, OutputTable, &Preflight{Reviewed: 4}))
+	assert.Equal(t, "All 4 permissions are granted\n", b.String())
+
+	b.Reset()
+	require.NoError(t, WritePreflight(&b, OutputJSON, &Preflight{Reviewed: 4}))
+	assert.JSONEq(t, `{"reviewed": 4, "missing": []}`, b.String())
+}
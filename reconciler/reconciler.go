@@ -0,0 +1,509 @@
+// Package reconciler finds the Pods matching the rules of a config, runs their checks and remediates them,
+// once (RunOnce) or every polling interval (Run)
+package reconciler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/andreistefanciprian/pod-restarter-go/audit"
+	"github.com/andreistefanciprian/pod-restarter-go/config"
+	"github.com/andreistefanciprian/pod-restarter-go/health"
+	k8s "github.com/andreistefanciprian/pod-restarter-go/kubernetes"
+	"github.com/andreistefanciprian/pod-restarter-go/logging"
+	"github.com/andreistefanciprian/pod-restarter-go/notify"
+	"k8s.io/utils/clock"
+)
+
+// Default options
+const (
+	DefaultHealTime        = 5 * time.Second
+	DefaultPollingInterval = 30 * time.Second
+	DefaultLookback        = 5 * time.Minute
+)
+
+// Options holds the settings of a Reconciler
+// HealTime gives Pending Pods time to self heal between listing and checking them
+// Lookback is how far back Events are processed by the first scan
+// Health, when set, tracks the loop of Cluster and is told about its successful listings and completed cycles
+// Notifier, when set, is sent the remediation outcomes of every cycle in one batch
+// Audit, when set, records every decision
+// Cluster names the cluster in multi-cluster mode; logs, audit records and notifications are labeled with it
+// Paused defers every remediation; PauseConfigMap ("namespace/name"), when set, pauses them while it has k8s.PauseAnnotation set to true
+// PauseClient, when set, reads the PauseConfigMap instead of the client (eg: in the cluster pod-restarter runs in, in multi-cluster mode)
+// Workers is the number of candidates checked and remediated at the same time (DefaultWorkers when zero);
+// their requests share the rate limiter of the client
+// MaxRemediations caps the remediations attempted by a cycle, failed or not, shared by the workers (0 disables the cap);
+// the candidates past it are deferred
+type Options struct {
+	Cluster         string
+	DryRun          bool
+	HealTime        time.Duration
+	PollingInterval time.Duration
+	Lookback        time.Duration
+	Health          *health.Status
+	Notifier        *notify.Notifier
+	Audit           *audit.Log
+	Paused          bool
+	PauseConfigMap  string
+	PauseClient     k8s.K8sClient
+	Workers         int
+	MaxRemediations int
+}
+
+// Errors of the candidates that passed their checks but were not remediated
+var (
+	ErrDeferred = errors.New("deferred by schedule")
+	ErrPaused   = errors.New("remediation paused")
+	ErrLimited  = errors.New("remediation limit of the cycle reached")
+)
+
+// Reconciler scans for failing Pods and remediates them with the check pipeline and action of every rule
+type Reconciler struct {
+	client    k8s.K8sClient
+	cfg       *config.Config
+	pipelines [][]k8s.Check
+	actions   []k8s.Action
+	opts      Options
+	clock     clock.Clock
+	log       *slog.Logger
+
+	// pauseNamespace and pauseName locate the pause ConfigMap, read by pauseClient
+	pauseNamespace, pauseName string
+	pauseClient               k8s.K8sClient
+
+	// cursors remember the Events processed by every rule,
+	// so that the next scans only look at new Events
+	cursors []*k8s.EventCursor
+	// inFlight counts the ready Pods admitted by the min-available checks of the current cycle
+	inFlight *k8s.InFlight
+	// remediations counts the remediations of the current cycle against MaxRemediations
+	remediations *budget
+	// imagePulls groups the Pods of image-pull rules by the image they fail to pull, nil without image-pull rules
+	imagePulls *k8s.ImagePulls
+	// requeued are the candidates deferred by the previous cycle, or that could not be checked or remediated,
+	// scanned again by the next one since the cursors have already processed their Events
+	requeued []Candidate
+}
+
+// Candidate is a Pod matched by a rule and the verdict of the rule checks
+// Err is set when the Pod could not be checked
+type Candidate struct {
+	Rule      string
+	Pod       string
+	Namespace string
+	Verdict   *k8s.Verdict
+	Err       error
+
+	rule int
+}
+
+// Summary counts the outcome of the candidates of a cycle
+type Summary struct {
+	Candidates int
+	Remediated int
+	Skipped    int
+	Deferred   int
+	Failed     int
+}
+
+// reloader is implemented by clients that can pick up kubeconfig changes
+type reloader interface {
+	Reload() error
+}
+
+// New returns a Reconciler for the rules of cfg
+// The check pipeline and the action of every rule are resolved once; clk times the heal time and the polling interval
+func New(client k8s.K8sClient, cfg *config.Config, clk clock.Clock, opts Options) (*Reconciler, error) {
+	if opts.PollingInterval <= 0 {
+		opts.PollingInterval = DefaultPollingInterval
+	}
+	if opts.Lookback < 0 {
+		return nil, fmt.Errorf("invalid lookback %s", opts.Lookback)
+	}
+	if opts.Workers < 0 {
+		return nil, fmt.Errorf("invalid number of workers %d", opts.Workers)
+	}
+	if opts.Workers == 0 {
+		opts.Workers = DefaultWorkers
+	}
+	if opts.MaxRemediations < 0 {
+		return nil, fmt.Errorf("invalid maximum of remediations per cycle %d", opts.MaxRemediations)
+	}
+	if opts.HealTime < 0 || opts.HealTime >= opts.PollingInterval {
+		return nil, fmt.Errorf("heal time %s must be shorter than the polling interval %s", opts.HealTime, opts.PollingInterval)
+	}
+	r := &Reconciler{
+		client:    client,
+		cfg:       cfg,
+		pipelines: make([][]k8s.Check, len(cfg.Rules)),
+		actions:   make([]k8s.Action, len(cfg.Rules)),
+		opts:      opts,
+		clock:     clk,
+		cursors:   make([]*k8s.EventCursor, len(cfg.Rules)),
+		inFlight:  k8s.NewInFlight(),
+		log:       slog.Default(),
+	}
+	r.remediations = &budget{max: opts.MaxRemediations}
+	r.pauseClient = client
+	if opts.PauseClient != nil {
+		r.pauseClient = opts.PauseClient
+	}
+	if opts.Cluster != "" {
+		r.log = r.log.With(logging.KeyCluster, opts.Cluster)
+	}
+	opts.Health.Track(opts.Cluster)
+	if opts.PauseConfigMap != "" {
+		var ok bool
+		r.pauseNamespace, r.pauseName, ok = strings.Cut(opts.PauseConfigMap, "/")
+		if !ok || r.pauseNamespace == "" || r.pauseName == "" {
+			return nil, fmt.Errorf("invalid pause ConfigMap %q: expected namespace/name", opts.PauseConfigMap)
+		}
+	}
+	for i, rule := range cfg.Rules {
+		r.cursors[i] = k8s.NewEventCursor(opts.Lookback)
+		var err error
+		r.pipelines[i], err = k8s.LookupChecks(rule.Checks)
+		if err == nil && rule.Type == config.RuleTypeImagePull {
+			if r.imagePulls == nil {
+				r.imagePulls = k8s.NewImagePulls(k8s.ScopeNamespaces(cfg.PodFilter.Namespaces), client.PageSize())
+			}
+			r.pipelines[i] = append(r.pipelines[i], k8s.NewImagePulledCheck(r.imagePulls))
+		}
+		if err == nil && rule.MinAvailable != nil {
+			var guard k8s.Check
+			guard, err = k8s.NewMinAvailableCheck(*rule.MinAvailable, r.inFlight)
+			r.pipelines[i] = append(r.pipelines[i], guard)
+		}
+		if err == nil {
+			r.actions[i], err = k8s.NewAction(rule.ActionSpec)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", rule.Name, err)
+		}
+	}
+	return r, nil
+}
+
+// Scan returns the Pods with Events matching every rule since the previous scan, and the deferred and failed candidates of the previous cycle,
+// with the verdict of the rule checks
+// Candidates are checked by the worker pool; a candidate that could not be checked has its Err set and never stops the others
+// The returned error joins the errors of the rules whose Pods could not be listed
+func (r *Reconciler) Scan(ctx context.Context) ([]Candidate, error) {
+	// generate a unique list of Pods for every rule
+	// we do this because a Pod might have multiple Events with the same Reason
+	var candidates []Candidate
+	var errs []error
+	r.inFlight.Reset()
+	if r.imagePulls != nil {
+		r.imagePulls.Reset()
+	}
+	seen := map[Candidate]bool{}
+	for _, c := range r.requeued {
+		seen[c] = true
+		candidates = append(candidates, c)
+	}
+	for i, rule := range r.cfg.Rules {
+		pods, err := r.client.GenerateToBeDeletedPodList(ctx, r.cfg.PodFilter, rule.Reason, rule.Message, r.cursors[i])
+		if err != nil {
+			r.log.Error("Could not generate list of Pods", logging.KeyRule, rule.Name, logging.Err(err))
+			errs = append(errs, fmt.Errorf("rule %q: %w", rule.Name, err))
+		}
+		for pod, ns := range pods {
+			c := Candidate{Rule: rule.Name, Pod: pod, Namespace: ns, rule: i}
+			if !seen[c] {
+				candidates = append(candidates, c)
+			}
+		}
+	}
+	if len(errs) == 0 {
+		r.opts.Health.Listed(r.opts.Cluster)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.rule != b.rule {
+			return a.rule < b.rule
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Pod < b.Pod
+	})
+	if len(candidates) == 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	// allow Pending Pods a few seconds to self heal
+	if !r.sleep(ctx, r.opts.HealTime) {
+		return nil, ctx.Err()
+	}
+
+	panics := parallel(len(candidates), r.opts.Workers, func(i int) {
+		c := &candidates[i]
+		c.Verdict, c.Err = r.client.PodChecks(ctx, c.Pod, c.Namespace, r.pipelines[c.rule])
+	})
+	for i, err := range panics {
+		if err != nil {
+			candidates[i].Verdict, candidates[i].Err = nil, err
+		}
+	}
+	r.logImagePulls()
+	return candidates, errors.Join(errs...)
+}
+
+// logImagePulls logs the Pods of image-pull rules grouped by the image they fail to pull
+func (r *Reconciler) logImagePulls() {
+	if r.imagePulls == nil {
+		return
+	}
+	for _, g := range r.imagePulls.Groups() {
+		if g.PulledOn == "" {
+			r.log.Warn("Image genuinely missing", "image", g.Image, "pods", g.Pods)
+			continue
+		}
+		r.log.Info("Image failing to pull was pulled on another node", "image", g.Image, "node", g.PulledOn, "pods", g.Pods)
+	}
+}
+
+// Remediate runs the rule action against every candidate that passed its checks
+// The action only plans the remediation in dry run mode
+// Candidates are deferred while remediations are paused or outside the schedule windows of their rule
+// Every decision is audited, and the remediated Pods and the failures are sent to the notifier once all candidates are handled
+func (r *Reconciler) Remediate(ctx context.Context, candidates []Candidate) Summary {
+	s := Summary{Candidates: len(candidates)}
+	if len(candidates) == 0 {
+		return s
+	}
+	paused := r.pause(ctx)
+	if paused != nil {
+		r.log.Warn("Remediations are paused", "reason", paused)
+	}
+	r.remediations.reset()
+
+	// candidates are remediated by the worker pool, then their outcomes are counted,
+	// audited and notified in candidate order
+	outcomes := make([]outcome, len(candidates))
+	panics := parallel(len(candidates), r.opts.Workers, func(i int) {
+		c, o := candidates[i], &outcomes[i]
+		o.decision, o.result, o.err = r.remediate(ctx, c, paused)
+		// the matched Events are only listed when they are audited or notified
+		if r.opts.Audit != nil || r.opts.Notifier != nil {
+			o.events = r.matchedEvents(ctx, c)
+		}
+	})
+
+	var notifications []notify.Notification
+	r.requeued = nil
+	for i, c := range candidates {
+		o := outcomes[i]
+		if panics[i] != nil {
+			r.logger(c).Error("Could not remediate Pod", logging.KeyOutcome, "failed", logging.Err(panics[i]))
+			o.decision, o.err = audit.DecisionError, panics[i]
+		}
+		switch {
+		case o.decision == audit.DecisionDefer:
+			s.Deferred++
+			r.requeued = append(r.requeued, Candidate{Rule: c.Rule, Pod: c.Pod, Namespace: c.Namespace, rule: c.rule})
+		case o.err != nil:
+			s.Failed++
+			r.requeued = append(r.requeued, Candidate{Rule: c.Rule, Pod: c.Pod, Namespace: c.Namespace, rule: c.rule})
+		case o.decision == audit.DecisionSkip:
+			s.Skipped++
+		default:
+			s.Remediated++
+		}
+
+		err := r.opts.Audit.Write(r.auditRecord(c, o.decision, o.events, o.result, o.err))
+		if err != nil {
+			r.logger(c).Error("Could not audit decision", logging.Err(err))
+		}
+		if o.decision != audit.DecisionSkip && o.decision != audit.DecisionDefer {
+			notifications = append(notifications, r.notification(c, o.events, o.result, o.err))
+		}
+	}
+
+	err := r.opts.Notifier.Send(ctx, notifications)
+	if err != nil {
+		r.log.Error("Could not send notifications", logging.Err(err))
+	}
+	return s
+}
+
+// outcome is the decision taken for a candidate by a worker, and the Events it matched
+type outcome struct {
+	decision string
+	result   *k8s.Result
+	err      error
+	events   []k8s.PodEvent
+}
+
+// remediate runs the rule action against a candidate that passed its checks and returns the decision taken
+// paused is the reason remediations are paused, if they are; deferred candidates return the deferral as error
+func (r *Reconciler) remediate(ctx context.Context, c Candidate, paused error) (string, *k8s.Result, error) {
+	podLogger := r.logger(c)
+	if c.Err != nil {
+		podLogger.Error("Could not check Pod", logging.KeyOutcome, "failed", logging.Err(c.Err))
+		return audit.DecisionError, nil, c.Err
+	}
+	if !c.Verdict.Restart {
+		podLogger.Info("Skipping Pod", logging.KeyOutcome, "skipped", "reason", c.Verdict.Reason)
+		return audit.DecisionSkip, nil, nil
+	}
+	deferral := r.deferral(paused, c.rule)
+	if deferral != nil {
+		podLogger.Info("Deferring Pod", logging.KeyOutcome, "deferred", "reason", deferral)
+		r.inFlight.Release(c.Verdict.Pod.UID)
+		return audit.DecisionDefer, nil, deferral
+	}
+
+	if !r.remediations.take() {
+		err := fmt.Errorf("%w: %d", ErrLimited, r.opts.MaxRemediations)
+		podLogger.Info("Deferring Pod", logging.KeyOutcome, "deferred", "reason", err)
+		r.inFlight.Release(c.Verdict.Pod.UID)
+		return audit.DecisionDefer, nil, err
+	}
+
+	result, err := r.client.Remediate(ctx, r.actions[c.rule], c.Verdict.Pod, r.opts.DryRun)
+	if err != nil {
+		podLogger.Error("Could not remediate Pod", logging.KeyOutcome, "failed", logging.Err(err))
+		r.inFlight.Release(c.Verdict.Pod.UID)
+		return audit.DecisionRemediate, result, err
+	}
+	podLogger.Info("Remediated Pod",
+		logging.KeyOutcome, result.Outcome,
+		"target", result.Plan.Target,
+		"plan", result.Plan.Description,
+	)
+	return audit.DecisionRemediate, result, nil
+}
+
+// pause returns why remediations are paused, or nil if they are not
+// The pause ConfigMap is read once per cycle; remediations stay paused when it cannot be read
+func (r *Reconciler) pause(ctx context.Context) error {
+	if r.opts.Paused {
+		return fmt.Errorf("%w by configuration", ErrPaused)
+	}
+	if r.pauseName == "" {
+		return nil
+	}
+	paused, err := r.pauseClient.Paused(ctx, r.pauseNamespace, r.pauseName)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrPaused, err)
+	}
+	if paused {
+		return fmt.Errorf("%w by the %s annotation on ConfigMap %s/%s", ErrPaused, k8s.PauseAnnotation, r.pauseNamespace, r.pauseName)
+	}
+	return nil
+}
+
+// deferral returns why the rule at index i must not remediate Pods now, or nil if it can
+func (r *Reconciler) deferral(paused error, i int) error {
+	if paused != nil {
+		return paused
+	}
+	now := r.clock.Now()
+	err := r.cfg.Schedule.Allows(now)
+	if err == nil {
+		err = r.cfg.Rules[i].Schedule.Allows(now)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrDeferred, err)
+	}
+	return nil
+}
+
+// Cluster returns the name of the cluster in multi-cluster mode
+func (r *Reconciler) Cluster() string {
+	return r.opts.Cluster
+}
+
+// Plan plans the rule action for a candidate that passed its checks, without executing it
+func (r *Reconciler) Plan(ctx context.Context, c Candidate) (*k8s.Plan, error) {
+	if c.Verdict == nil || !c.Verdict.Restart {
+		return nil, fmt.Errorf("Pod %s/%s must not be restarted", c.Namespace, c.Pod)
+	}
+	result, err := r.client.Remediate(ctx, r.actions[c.rule], c.Verdict.Pod, true)
+	if err != nil {
+		return nil, err
+	}
+	return result.Plan, nil
+}
+
+// RunOnce runs a single scan and remediation cycle
+func (r *Reconciler) RunOnce(ctx context.Context) (Summary, error) {
+	candidates, err := r.Scan(ctx)
+	s := r.Remediate(ctx, candidates)
+	r.opts.Health.CycleCompleted(r.opts.Cluster)
+	r.log.Info("Finished iteration",
+		"candidates", s.Candidates,
+		"remediated", s.Remediated,
+		"skipped", s.Skipped,
+		"deferred", s.Deferred,
+		"failed", s.Failed,
+	)
+	return s, err
+}
+
+// Run runs a cycle every polling interval until ctx is cancelled
+func (r *Reconciler) Run(ctx context.Context) {
+	for {
+		r.log.Info("Starting iteration", "polling_interval", r.interval())
+
+		// pick up kubeconfig changes (eg: rotated credentials) without rebuilding the client every cycle
+		if c, ok := r.client.(reloader); ok {
+			err := c.Reload()
+			if err != nil {
+				r.log.Error("Could not reload kubernetes client", logging.Err(err))
+			}
+		}
+
+		start := r.clock.Now()
+		r.RunOnce(ctx)
+		if !r.sleep(ctx, r.opts.PollingInterval-r.clock.Since(start)) {
+			return
+		}
+	}
+}
+
+// interval returns the polling interval in seconds
+func (r *Reconciler) interval() int {
+	return int(r.opts.PollingInterval / time.Second)
+}
+
+// logger returns a logger with the attributes of the candidate Pod, rule and action
+func (r *Reconciler) logger(c Candidate) *slog.Logger {
+	podLogger := r.log.With(
+		logging.KeyNamespace, c.Namespace,
+		logging.KeyPod, c.Pod,
+		logging.KeyRule, c.Rule,
+		logging.KeyAction, r.actions[c.rule].Name(),
+		logging.KeyDryRun, r.opts.DryRun,
+	)
+	if c.Verdict == nil {
+		return podLogger
+	}
+	if c.Verdict.Pod != nil {
+		podLogger = podLogger.With(logging.KeyUID, c.Verdict.Pod.UID, logging.KeyOwner, c.Verdict.Pod.Owner())
+	}
+	return podLogger.With("checks", c.Verdict.Checks)
+}
+
+// sleep waits for d on the reconciler clock and returns false if ctx is cancelled first
+func (r *Reconciler) sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := r.clock.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C():
+		return true
+	}
+}
@@ -0,0 +1,864 @@
+package reconciler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andreistefanciprian/pod-restarter-go/audit"
+	"github.com/andreistefanciprian/pod-restarter-go/config"
+	"github.com/andreistefanciprian/pod-restarter-go/health"
+	k8s "github.com/andreistefanciprian/pod-restarter-go/kubernetes"
+	"github.com/andreistefanciprian/pod-restarter-go/notify"
+	"github.com/andreistefanciprian/pod-restarter-go/schedule"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+const (
+	vethReason  = "FailedCreatePodSandBox"
+	vethMessage = "container veth name provided (eth0) already exists"
+)
+
+var testNow = time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+
+func makePod(name string, owned bool, phase v1.PodPhase) *v1.Pod {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "default",
+			UID:               types.UID(name + "-uid"),
+			CreationTimestamp: metav1.NewTime(testNow.Add(-time.Minute)),
+		},
+		Status: v1.PodStatus{Phase: phase},
+	}
+	if owned {
+		controller := true
+		pod.OwnerReferences = []metav1.OwnerReference{{Kind: "ReplicaSet", Name: name + "-rs", Controller: &controller}}
+	}
+	return pod
+}
+
+func makeEvent(pod, reason, message string) *v1.Event {
+	return &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            pod + "." + reason,
+			Namespace:       "default",
+			UID:             types.UID(pod + "." + reason + "-uid"),
+			ResourceVersion: "1",
+		},
+		InvolvedObject: v1.ObjectReference{Kind: "Pod", Name: pod, Namespace: "default", UID: types.UID(pod + "-uid")},
+		Reason:         reason,
+		Message:        message,
+		Type:           v1.EventTypeWarning,
+		LastTimestamp:  metav1.NewTime(testNow),
+	}
+}
+
+func newTestReconciler(t *testing.T, objects []runtime.Object, opts Options) (*Reconciler, *fake.Clientset, *testingclock.FakeClock) {
+	t.Helper()
+	cfg := &config.Config{Rules: []config.Rule{{Name: "veth", Reason: vethReason, Message: vethMessage}}}
+	require.NoError(t, cfg.Validate())
+
+	clientSet := fake.NewSimpleClientset(objects...)
+	clk := testingclock.NewFakeClock(testNow)
+	r, err := New(k8s.NewK8sClientFromClientSet(clientSet, 0, clk), cfg, clk, opts)
+	require.NoError(t, err)
+	return r, clientSet, clk
+}
+
+// runOnce runs a cycle, calls duringHeal while the Reconciler waits for Pods to self heal, then steps the clock past the heal time
+func runOnce(t *testing.T, r *Reconciler, clk *testingclock.FakeClock, duringHeal func()) (Summary, error) {
+	t.Helper()
+	type result struct {
+		summary Summary
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		s, err := r.RunOnce(context.Background())
+		done <- result{s, err}
+	}()
+
+	healed := false
+	for {
+		select {
+		case res := <-done:
+			return res.summary, res.err
+		default:
+		}
+		if !healed && clk.HasWaiters() {
+			if duringHeal != nil {
+				duringHeal()
+			}
+			clk.Step(r.opts.HealTime)
+			healed = true
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRunOnce(t *testing.T) {
+	tests := map[string]struct {
+		objects         []runtime.Object
+		dryRun          bool
+		duringHeal      func(t *testing.T, clientSet *fake.Clientset)
+		expectedSummary Summary
+		expectPod       bool
+	}{
+		"veth error on owned Pod deletes the Pod": {
+			objects: []runtime.Object{
+				makePod("foo", true, v1.PodPending),
+				makeEvent("foo", vethReason, vethMessage+" ...."),
+			},
+			expectedSummary: Summary{Candidates: 1, Remediated: 1},
+			expectPod:       false,
+		},
+		"veth error on owned Pod in dry run mode keeps the Pod": {
+			objects: []runtime.Object{
+				makePod("foo", true, v1.PodPending),
+				makeEvent("foo", vethReason, vethMessage),
+			},
+			dryRun:          true,
+			expectedSummary: Summary{Candidates: 1, Remediated: 1},
+			expectPod:       true,
+		},
+		"Bare Pod is skipped": {
+			objects: []runtime.Object{
+				makePod("foo", false, v1.PodPending),
+				makeEvent("foo", vethReason, vethMessage),
+			},
+			expectedSummary: Summary{Candidates: 1, Skipped: 1},
+			expectPod:       true,
+		},
+		"Pod that self-healed is skipped": {
+			objects: []runtime.Object{
+				makePod("foo", true, v1.PodPending),
+				makeEvent("foo", vethReason, vethMessage),
+			},
+			duringHeal: func(t *testing.T, clientSet *fake.Clientset) {
+				pod := makePod("foo", true, v1.PodRunning)
+				pod.Status.ContainerStatuses = []v1.ContainerStatus{{
+					Name:  "app",
+					Ready: true,
+					State: v1.ContainerState{Running: &v1.ContainerStateRunning{}},
+				}}
+				_, err := clientSet.CoreV1().Pods("default").Update(context.TODO(), pod, metav1.UpdateOptions{})
+				require.NoError(t, err)
+			},
+			expectedSummary: Summary{Candidates: 1, Skipped: 1},
+			expectPod:       true,
+		},
+		"Pod deleted mid-cycle is skipped": {
+			objects: []runtime.Object{
+				makePod("foo", true, v1.PodPending),
+				makeEvent("foo", vethReason, vethMessage),
+			},
+			duringHeal: func(t *testing.T, clientSet *fake.Clientset) {
+				err := clientSet.CoreV1().Pods("default").Delete(context.TODO(), "foo", metav1.DeleteOptions{})
+				require.NoError(t, err)
+			},
+			expectedSummary: Summary{Candidates: 1, Skipped: 1},
+			expectPod:       false,
+		},
+		"Pod without matching Events is ignored": {
+			objects: []runtime.Object{
+				makePod("foo", true, v1.PodPending),
+				makeEvent("foo", "BackOff", "Back-off pulling image"),
+			},
+			expectedSummary: Summary{},
+			expectPod:       true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r, clientSet, clk := newTestReconciler(t, tc.objects, Options{
+				DryRun:          tc.dryRun,
+				HealTime:        DefaultHealTime,
+				PollingInterval: DefaultPollingInterval,
+			})
+
+			s, err := runOnce(t, r, clk, func() {
+				if tc.duringHeal != nil {
+					tc.duringHeal(t, clientSet)
+				}
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedSummary, s)
+
+			_, err = clientSet.CoreV1().Pods("default").Get(context.TODO(), "foo", metav1.GetOptions{})
+			if tc.expectPod {
+				assert.NoError(t, err, "Expected Pod to exist")
+			} else {
+				assert.True(t, apierrors.IsNotFound(err), "Expected Pod to be deleted")
+			}
+		})
+	}
+}
+
+func TestScanVerdicts(t *testing.T) {
+	r, _, clk := newTestReconciler(t, []runtime.Object{
+		makePod("bare", false, v1.PodPending),
+		makeEvent("bare", vethReason, vethMessage),
+		makePod("foo", true, v1.PodPending),
+		makeEvent("foo", vethReason, vethMessage),
+	}, Options{HealTime: 0})
+
+	candidates, err := r.Scan(context.Background())
+	require.NoError(t, err)
+	require.Len(t, candidates, 2)
+
+	assert.Equal(t, "bare", candidates[0].Pod)
+	assert.Equal(t, "veth", candidates[0].Rule)
+	assert.False(t, candidates[0].Verdict.Restart)
+	assert.ErrorIs(t, candidates[0].Verdict.Reason, k8s.ErrNoOwner)
+
+	assert.Equal(t, "foo", candidates[1].Pod)
+	assert.True(t, candidates[1].Verdict.Restart)
+	plan, err := r.Plan(context.Background(), candidates[1])
+	require.NoError(t, err)
+	assert.Equal(t, "Pod/foo", plan.Target)
+	assert.Equal(t, clk.Now(), plan.Time)
+
+	_, err = r.Plan(context.Background(), candidates[0])
+	assert.Error(t, err)
+}
+
+func TestScanProcessesEventsOnce(t *testing.T) {
+	stale := makeEvent("bar", vethReason, vethMessage)
+	stale.LastTimestamp = metav1.NewTime(testNow.Add(-time.Hour))
+	r, clientSet, clk := newTestReconciler(t, []runtime.Object{
+		makePod("foo", true, v1.PodPending),
+		makeEvent("foo", vethReason, vethMessage),
+		makePod("bar", true, v1.PodPending),
+		stale,
+	}, Options{PollingInterval: 30 * time.Second, Lookback: 5 * time.Minute})
+
+	scan := func() []string {
+		candidates, err := r.Scan(context.Background())
+		require.NoError(t, err)
+		var pods []string
+		for _, c := range candidates {
+			pods = append(pods, c.Pod)
+		}
+		return pods
+	}
+
+	// the first scan ignores Events older than the lookback
+	assert.Equal(t, []string{"foo"}, scan())
+
+	// the next scan ignores the Events already processed
+	clk.Step(30 * time.Second)
+	assert.Empty(t, scan())
+
+	// an Event seen again is processed again
+	clk.Step(30 * time.Second)
+	event := makeEvent("foo", vethReason, vethMessage)
+	event.LastTimestamp = metav1.NewTime(clk.Now())
+	event.ResourceVersion = "2"
+	_, err := clientSet.CoreV1().Events("default").Update(context.TODO(), event, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"foo"}, scan())
+
+	// a new Event is processed even when it was stamped before the previous scan (eg: during the heal time)
+	clk.Step(30 * time.Second)
+	late := makeEvent("baz", vethReason, vethMessage)
+	late.LastTimestamp = metav1.NewTime(clk.Now().Add(-time.Minute))
+	_, err = clientSet.CoreV1().Events("default").Create(context.TODO(), late, metav1.CreateOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"baz"}, scan())
+}
+
+func TestRunOnceHealth(t *testing.T) {
+	r, clientSet, clk := newTestReconciler(t, nil, Options{})
+	status := health.NewStatus(clk, time.Minute)
+	r.opts.Health = status
+
+	// listing failures keep the Reconciler not ready, but the cycle completes
+	clientSet.PrependReactor("list", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("connection refused")
+	})
+	clk.Step(50 * time.Second)
+	_, err := r.RunOnce(context.Background())
+	assert.Error(t, err)
+	assert.Error(t, status.Ready())
+	clk.Step(50 * time.Second)
+	assert.NoError(t, status.Live())
+
+	clientSet.ReactionChain = clientSet.ReactionChain[1:]
+	_, err = r.RunOnce(context.Background())
+	require.NoError(t, err)
+	assert.NoError(t, status.Ready())
+}
+
+func TestRemediateNotifies(t *testing.T) {
+	var batches []notify.Batch
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var batch notify.Batch
+		assert.NoError(t, json.NewDecoder(req.Body).Decode(&batch))
+		batches = append(batches, batch)
+	}))
+	defer server.Close()
+
+	pod := makePod("foo", true, v1.PodPending)
+	pod.Spec.NodeName = "node-1"
+	r, _, clk := newTestReconciler(t, []runtime.Object{
+		pod,
+		makeEvent("foo", vethReason, vethMessage+" ...."),
+		makePod("bar", false, v1.PodPending),
+		makeEvent("bar", vethReason, vethMessage),
+	}, Options{})
+	notifier, err := notify.New([]notify.Webhook{{URL: server.URL}}, clk)
+	require.NoError(t, err)
+	r.opts.Notifier = notifier
+
+	s, err := r.RunOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, Summary{Candidates: 2, Remediated: 1, Skipped: 1}, s)
+
+	// skipped Pods are not notified
+	require.Len(t, batches, 1)
+	assert.Equal(t, []notify.Notification{{
+		Time:      testNow,
+		Namespace: "default",
+		Pod:       "foo",
+		Owner:     "ReplicaSet/foo-rs",
+		Node:      "node-1",
+		Rule:      "veth",
+		Message:   vethMessage + " ....",
+		Action:    k8s.ActionDelete,
+		Outcome:   "deleted",
+	}}, batches[0].Notifications)
+
+	// cycles without outcomes are not notified
+	_, err = r.RunOnce(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, batches, 1)
+}
+
+func TestRemediateAudits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	auditor, err := audit.Open(path, 0, 0, nil)
+	require.NoError(t, err)
+
+	r, clientSet, _ := newTestReconciler(t, []runtime.Object{
+		makePod("foo", true, v1.PodPending),
+		makeEvent("foo", vethReason, vethMessage),
+		makePod("bar", false, v1.PodPending),
+		makeEvent("bar", vethReason, vethMessage),
+		makePod("baz", true, v1.PodPending),
+		makeEvent("baz", vethReason, vethMessage),
+	}, Options{DryRun: false, Audit: auditor})
+	clientSet.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.(k8stesting.DeleteAction).GetName() == "baz" {
+			return true, nil, apierrors.NewForbidden(v1.Resource("pods"), "baz", errors.New("denied"))
+		}
+		return false, nil, nil
+	})
+
+	s, err := r.RunOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, Summary{Candidates: 3, Remediated: 1, Skipped: 1, Failed: 1}, s)
+	require.NoError(t, auditor.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	hash, err := audit.Verify(bytes.NewReader(data), "", nil)
+	require.NoError(t, err)
+	assert.NotEmpty(t, hash)
+
+	records := map[string]map[string]any{}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var entry audit.Entry
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		var rec map[string]any
+		require.NoError(t, json.Unmarshal(entry.Record, &rec))
+		records[rec["pod"].(string)] = rec
+	}
+	require.Len(t, records, 3)
+
+	assert.Equal(t, audit.DecisionSkip, records["bar"]["decision"])
+	assert.Contains(t, records["bar"]["reason"], "does not have owner")
+	assert.Nil(t, records["bar"]["response"])
+
+	assert.Equal(t, audit.DecisionRemediate, records["foo"]["decision"])
+	assert.Equal(t, "deleted", records["foo"]["outcome"])
+	assert.Equal(t, "OK", records["foo"]["response"])
+	assert.Equal(t, "ReplicaSet/foo-rs", records["foo"]["owner"])
+	assert.Len(t, records["foo"]["events"], 1)
+	assert.NotEmpty(t, records["foo"]["checks"])
+	assert.Equal(t, "Pod/foo", records["foo"]["plan"].(map[string]any)["target"])
+
+	assert.Equal(t, audit.DecisionRemediate, records["baz"]["decision"])
+	assert.Equal(t, "failed", records["baz"]["outcome"])
+	assert.Contains(t, records["baz"]["response"], "forbidden")
+}
+
+func TestRemediateWorkers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	auditor, err := audit.Open(path, 0, 0, nil)
+	require.NoError(t, err)
+
+	var objects []runtime.Object
+	var names []string
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("pod-%02d", i)
+		names = append(names, name)
+		objects = append(objects, makePod(name, true, v1.PodPending), makeEvent(name, vethReason, vethMessage))
+	}
+	r, clientSet, _ := newTestReconciler(t, objects, Options{Workers: 4, Audit: auditor})
+
+	clientSet.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.(k8stesting.DeleteAction).GetName() == "pod-07" {
+			return true, nil, apierrors.NewForbidden(v1.Resource("pods"), "pod-07", errors.New("denied"))
+		}
+		return false, nil, nil
+	})
+	// a worker that panics only fails its own candidate
+	clientSet.PrependReactor("get", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.(k8stesting.GetAction).GetName() == "pod-03" {
+			panic("boom")
+		}
+		return false, nil, nil
+	})
+
+	s, err := r.RunOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, Summary{Candidates: 20, Remediated: 18, Failed: 2}, s)
+	require.NoError(t, auditor.Close())
+
+	// decisions are audited in candidate order
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var audited []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var entry audit.Entry
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		var rec audit.Record
+		require.NoError(t, json.Unmarshal(entry.Record, &rec))
+		audited = append(audited, rec.Pod)
+	}
+	assert.Equal(t, names, audited)
+}
+
+func TestRemediateMaxRemediations(t *testing.T) {
+	var objects []runtime.Object
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("pod-%02d", i)
+		objects = append(objects, makePod(name, true, v1.PodPending), makeEvent(name, vethReason, vethMessage))
+	}
+	r, clientSet, _ := newTestReconciler(t, objects, Options{Workers: 4, MaxRemediations: 3})
+	remaining := func() int {
+		pods, err := clientSet.CoreV1().Pods("default").List(context.Background(), metav1.ListOptions{})
+		require.NoError(t, err)
+		return len(pods.Items)
+	}
+
+	// the workers share the cap of every cycle, and the Pods past it are checked again by the next cycles
+	for _, expected := range []Summary{
+		{Candidates: 10, Remediated: 3, Deferred: 7},
+		{Candidates: 7, Remediated: 3, Deferred: 4},
+		{Candidates: 4, Remediated: 3, Deferred: 1},
+		{Candidates: 1, Remediated: 1},
+	} {
+		s, err := r.RunOnce(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, expected, s)
+		assert.Equal(t, expected.Deferred, remaining())
+	}
+
+	_, err := New(r.client, r.cfg, r.clock, Options{MaxRemediations: -1})
+	assert.Error(t, err)
+}
+
+func TestRemediateDefers(t *testing.T) {
+	pauseConfigMap := func(value string) *v1.ConfigMap {
+		return &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name:        "pod-restarter",
+			Namespace:   "kube-system",
+			Annotations: map[string]string{k8s.PauseAnnotation: value},
+		}}
+	}
+	// pauseClient reads the pause ConfigMap from another cluster
+	pauseClient := func(objects ...runtime.Object) k8s.K8sClient {
+		return k8s.NewK8sClientFromClientSet(fake.NewSimpleClientset(objects...), 0, testingclock.NewFakeClock(testNow))
+	}
+	// testNow is a Sunday, inside a weekend freeze
+	weekendFreeze := &schedule.Schedule{Deny: []schedule.Window{{Cron: "0 18 * * FRI", Duration: metav1.Duration{Duration: 63 * time.Hour}}}}
+
+	testCases := []struct {
+		testName     string
+		objects      []runtime.Object
+		opts         Options
+		schedule     *schedule.Schedule
+		ruleSchedule *schedule.Schedule
+		reactor      k8stesting.ReactionFunc
+		wantErr      error
+	}{
+		{
+			testName: "Paused by flag",
+			opts:     Options{Paused: true},
+			wantErr:  ErrPaused,
+		},
+		{
+			testName: "Paused by ConfigMap",
+			objects:  []runtime.Object{pauseConfigMap("true")},
+			opts:     Options{PauseConfigMap: "kube-system/pod-restarter"},
+			wantErr:  ErrPaused,
+		},
+		{
+			testName: "Unreadable ConfigMap pauses",
+			opts:     Options{PauseConfigMap: "kube-system/pod-restarter"},
+			reactor: func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, nil, apierrors.NewForbidden(v1.Resource("configmaps"), "pod-restarter", errors.New("denied"))
+			},
+			wantErr: ErrPaused,
+		},
+		{
+			testName: "Global blackout window",
+			schedule: weekendFreeze,
+			wantErr:  ErrDeferred,
+		},
+		{
+			testName:     "Rule blackout window",
+			ruleSchedule: weekendFreeze,
+			wantErr:      ErrDeferred,
+		},
+		{
+			testName: "Resumed ConfigMap",
+			objects:  []runtime.Object{pauseConfigMap("false")},
+			opts:     Options{PauseConfigMap: "kube-system/pod-restarter"},
+		},
+		{
+			testName: "Paused by the ConfigMap of the pause client",
+			opts:     Options{PauseConfigMap: "kube-system/pod-restarter", PauseClient: pauseClient(pauseConfigMap("true"))},
+			wantErr:  ErrPaused,
+		},
+		{
+			testName: "ConfigMap of the cluster ignored with a pause client",
+			objects:  []runtime.Object{pauseConfigMap("true")},
+			opts:     Options{PauseConfigMap: "kube-system/pod-restarter", PauseClient: pauseClient()},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			objects := append([]runtime.Object{
+				makePod("foo", true, v1.PodPending),
+				makeEvent("foo", vethReason, vethMessage),
+			}, test.objects...)
+			r, clientSet, clk := newTestReconciler(t, objects, test.opts)
+			r.cfg.Schedule = test.schedule
+			r.cfg.Rules[0].Schedule = test.ruleSchedule
+			for _, s := range []*schedule.Schedule{test.schedule, test.ruleSchedule} {
+				if s != nil {
+					require.NoError(t, s.Validate())
+				}
+			}
+			if test.reactor != nil {
+				clientSet.PrependReactor("get", "configmaps", test.reactor)
+			}
+
+			candidates, err := r.Scan(context.Background())
+			require.NoError(t, err)
+			require.Len(t, candidates, 1)
+			s := r.Remediate(context.Background(), candidates)
+			_, getErr := clientSet.CoreV1().Pods("default").Get(context.Background(), "foo", metav1.GetOptions{})
+			if test.wantErr == nil {
+				assert.Equal(t, Summary{Candidates: 1, Remediated: 1}, s)
+				assert.True(t, apierrors.IsNotFound(getErr))
+				return
+			}
+			assert.Equal(t, Summary{Candidates: 1, Deferred: 1}, s)
+			assert.NoError(t, getErr)
+			_, _, err = r.remediate(context.Background(), candidates[0], r.pause(context.Background()))
+			assert.ErrorIs(t, err, test.wantErr)
+
+			// deferred Pods are checked again by the next cycles, although their Events were already processed
+			r.opts.Paused = false
+			r.pauseName = ""
+			clk.Step(24 * time.Hour)
+			s, err = r.RunOnce(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, Summary{Candidates: 1, Remediated: 1}, s)
+		})
+	}
+}
+
+func TestRemediateRequeuesFailures(t *testing.T) {
+	testCases := []struct {
+		testName string
+		verb     string
+	}{
+		{testName: "Check failed", verb: "get"},
+		{testName: "Action failed", verb: "delete"},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			r, clientSet, _ := newTestReconciler(t, []runtime.Object{
+				makePod("foo", true, v1.PodPending),
+				makeEvent("foo", vethReason, vethMessage),
+			}, Options{})
+			failed := false
+			clientSet.PrependReactor(test.verb, "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				if failed {
+					return false, nil, nil
+				}
+				failed = true
+				return true, nil, apierrors.NewServiceUnavailable("etcd leader changed")
+			})
+
+			s, err := r.RunOnce(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, Summary{Candidates: 1, Failed: 1}, s)
+
+			// failed Pods are checked again by the next cycle, although their Events were already processed
+			s, err = r.RunOnce(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, Summary{Candidates: 1, Remediated: 1}, s)
+			_, err = clientSet.CoreV1().Pods("default").Get(context.Background(), "foo", metav1.GetOptions{})
+			assert.True(t, apierrors.IsNotFound(err))
+
+			s, err = r.RunOnce(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, Summary{}, s)
+		})
+	}
+}
+
+func TestRemediateKeepsMinAvailable(t *testing.T) {
+	replicas := int32(2)
+	rs := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-rs", Namespace: "default"},
+		Spec:       appsv1.ReplicaSetSpec{Replicas: &replicas},
+		Status:     appsv1.ReplicaSetStatus{ReadyReplicas: 2},
+	}
+	objects := []runtime.Object{rs}
+	for _, name := range []string{"web-a", "web-b"} {
+		pod := makePod(name, true, v1.PodRunning)
+		pod.OwnerReferences[0].Name = "web-rs"
+		pod.Status.Conditions = []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}}
+		pod.Status.ContainerStatuses = []v1.ContainerStatus{{Name: "app", State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: 1}}}}
+		objects = append(objects, pod, makeEvent(name, vethReason, vethMessage))
+	}
+
+	cfg := &config.Config{
+		Rules:        []config.Rule{{Name: "veth", Reason: vethReason, Message: vethMessage}},
+		MinAvailable: &intstr.IntOrString{Type: intstr.Int, IntVal: 1},
+	}
+	require.NoError(t, cfg.Validate())
+	clientSet := fake.NewSimpleClientset(objects...)
+	clk := testingclock.NewFakeClock(testNow)
+	r, err := New(k8s.NewK8sClientFromClientSet(clientSet, 0, clk), cfg, clk, Options{})
+	require.NoError(t, err)
+
+	candidates, err := r.Scan(context.Background())
+	require.NoError(t, err)
+	require.Len(t, candidates, 2)
+	assert.True(t, candidates[0].Verdict.Restart)
+	assert.False(t, candidates[1].Verdict.Restart)
+	assert.ErrorIs(t, candidates[1].Verdict.Reason, k8s.ErrBelowMinAvailable)
+
+	s := r.Remediate(context.Background(), candidates)
+	assert.Equal(t, Summary{Candidates: 2, Remediated: 1, Skipped: 1}, s)
+}
+
+func TestScanImagePull(t *testing.T) {
+	backOff := func(pod, image string) *v1.Event {
+		return makeEvent(pod, "BackOff", `Back-off pulling image "`+image+`"`)
+	}
+	pulled := makeEvent("web-0", "Pulled", `Successfully pulled image "web:1.2" in 1s`)
+	pulled.Type = v1.EventTypeNormal
+	objects := []runtime.Object{pulled}
+	for name, image := range map[string]string{"web-a": "web:1.2", "web-b": "web:1.2", "api-a": "api:9.9"} {
+		pod := makePod(name, true, v1.PodPending)
+		pod.Status.ContainerStatuses = []v1.ContainerStatus{{Name: "app", Image: image, State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "ImagePullBackOff"}}}}
+		objects = append(objects, pod, backOff(name, image))
+	}
+
+	cfg := &config.Config{Rules: []config.Rule{{Type: config.RuleTypeImagePull}}}
+	require.NoError(t, cfg.Validate())
+	clientSet := fake.NewSimpleClientset(objects...)
+	clk := testingclock.NewFakeClock(testNow)
+	r, err := New(k8s.NewK8sClientFromClientSet(clientSet, 0, clk), cfg, clk, Options{})
+	require.NoError(t, err)
+
+	candidates, err := r.Scan(context.Background())
+	require.NoError(t, err)
+	require.Len(t, candidates, 3)
+	for _, c := range candidates {
+		require.NoError(t, c.Err)
+		if c.Pod == "api-a" {
+			assert.False(t, c.Verdict.Restart)
+			assert.ErrorIs(t, c.Verdict.Reason, k8s.ErrImageMissing)
+			continue
+		}
+		assert.True(t, c.Verdict.Restart, c.Pod)
+	}
+	assert.Equal(t, []k8s.ImageGroup{
+		{Image: "api:9.9", Pods: []string{"default/api-a"}},
+		{Image: "web:1.2", Pods: []string{"default/web-a", "default/web-b"}, PulledOn: "unknown"},
+	}, r.imagePulls.Groups())
+}
+
+func TestReportDeferred(t *testing.T) {
+	r, _, _ := newTestReconciler(t, []runtime.Object{
+		makePod("foo", true, v1.PodPending),
+		makeEvent("foo", vethReason, vethMessage),
+	}, Options{Paused: true})
+
+	candidates, err := r.Scan(context.Background())
+	require.NoError(t, err)
+	doc := r.Report(context.Background(), candidates)
+	require.Len(t, doc.Candidates, 1)
+	assert.Equal(t, "remediation paused by configuration", doc.Candidates[0].Deferred)
+	assert.False(t, doc.Candidates[0].Failed())
+
+	var out bytes.Buffer
+	require.NoError(t, WriteReport(&out, OutputTable, doc))
+	assert.Contains(t, out.String(), "deferred")
+}
+
+func TestReportCluster(t *testing.T) {
+	r, _, _ := newTestReconciler(t, []runtime.Object{
+		makePod("foo", true, v1.PodPending),
+		makeEvent("foo", vethReason, vethMessage),
+	}, Options{Cluster: "prod-eu"})
+	assert.Equal(t, "prod-eu", r.Cluster())
+
+	candidates, err := r.Scan(context.Background())
+	require.NoError(t, err)
+	doc := r.Report(context.Background(), candidates)
+	require.Len(t, doc.Candidates, 1)
+	assert.Equal(t, "prod-eu", doc.Candidates[0].Cluster)
+
+	var out bytes.Buffer
+	require.NoError(t, WriteReport(&out, OutputTable, doc))
+	lines := strings.Split(out.String(), "\n")
+	assert.True(t, strings.HasPrefix(lines[0], "CLUSTER"))
+	assert.True(t, strings.HasPrefix(lines[1], "prod-eu"))
+	assert.Equal(t, "prod-eu", r.auditRecord(candidates[0], audit.DecisionRemediate, nil, nil, nil).Cluster)
+}
+
+func TestRun(t *testing.T) {
+	r, clientSet, clk := newTestReconciler(t, []runtime.Object{
+		makePod("foo", true, v1.PodPending),
+		makeEvent("foo", vethReason, vethMessage),
+	}, Options{HealTime: DefaultHealTime, PollingInterval: DefaultPollingInterval})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(stopped)
+	}()
+
+	// first iteration waits for the heal time, then for the rest of the polling interval
+	for !clk.HasWaiters() {
+		time.Sleep(time.Millisecond)
+	}
+	clk.Step(DefaultHealTime)
+	require.Eventually(t, func() bool {
+		_, err := clientSet.CoreV1().Pods("default").Get(context.TODO(), "foo", metav1.GetOptions{})
+		return apierrors.IsNotFound(err) && clk.HasWaiters()
+	}, 5*time.Second, time.Millisecond)
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Run to stop when the context is cancelled")
+	}
+}
+
+func TestNewRejectsHealTimeLongerThanPollingInterval(t *testing.T) {
+	cfg := &config.Config{Rules: []config.Rule{{Reason: vethReason}}}
+	require.NoError(t, cfg.Validate())
+	clk := testingclock.NewFakeClock(testNow)
+
+	_, err := New(k8s.NewK8sClientFromClientSet(fake.NewSimpleClientset(), 0, clk), cfg, clk, Options{
+		HealTime:        time.Minute,
+		PollingInterval: 30 * time.Second,
+	})
+	assert.Error(t, err)
+
+	_, err = New(k8s.NewK8sClientFromClientSet(fake.NewSimpleClientset(), 0, clk), cfg, clk, Options{Workers: -1})
+	assert.Error(t, err)
+
+	// library users and the CLI share the default number of workers
+	r, err := New(k8s.NewK8sClientFromClientSet(fake.NewSimpleClientset(), 0, clk), cfg, clk, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultWorkers, r.opts.Workers)
+}
+
+func TestExplain(t *testing.T) {
+	r, _, _ := newTestReconciler(t, []runtime.Object{
+		makePod("foo", true, v1.PodPending),
+		makeEvent("foo", vethReason, vethMessage),
+		makePod("bar", false, v1.PodPending),
+	}, Options{})
+
+	var out bytes.Buffer
+	require.NoError(t, r.Explain(context.Background(), &out, "default", "foo"))
+	assert.Contains(t, out.String(), "Owner:  ReplicaSet/foo-rs")
+	assert.Contains(t, out.String(), vethReason)
+	assert.Contains(t, out.String(), "would delete Pod default/foo")
+
+	out.Reset()
+	require.NoError(t, r.Explain(context.Background(), &out, "default", "bar"))
+	assert.Contains(t, out.String(), "<none>")
+	assert.Contains(t, out.String(), "would not restart: no Event matches the rule")
+}
+
+func TestReconcilerSleep(t *testing.T) {
+	clk := testingclock.NewFakeClock(testNow)
+	r := &Reconciler{clock: clk}
+
+	// the sleep returns once the fake clock has stepped past the duration
+	done := make(chan bool)
+	go func() {
+		done <- r.sleep(context.Background(), 30*time.Second)
+	}()
+	for !clk.HasWaiters() {
+		time.Sleep(time.Millisecond)
+	}
+	clk.Step(29 * time.Second)
+	select {
+	case <-done:
+		t.Fatal("Expected sleep to wait for the full duration")
+	default:
+	}
+	clk.Step(time.Second)
+	assert.True(t, <-done)
+
+	// the sleep returns early when the context is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		done <- r.sleep(ctx, time.Hour)
+	}()
+	cancel()
+	assert.False(t, <-done)
+
+	assert.True(t, r.sleep(context.Background(), 0))
+}
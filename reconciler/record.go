@@ -0,0 +1,96 @@
+package reconciler
+
+import (
+	"context"
+
+	"github.com/andreistefanciprian/pod-restarter-go/audit"
+	k8s "github.com/andreistefanciprian/pod-restarter-go/kubernetes"
+	"github.com/andreistefanciprian/pod-restarter-go/logging"
+	"github.com/andreistefanciprian/pod-restarter-go/notify"
+)
+
+// matchedEvents returns the Events of the candidate Pod that match its rule, sorted by LastTimestamp
+func (r *Reconciler) matchedEvents(ctx context.Context, c Candidate) []k8s.PodEvent {
+	rule := r.cfg.Rules[c.rule]
+	events, err := r.client.PodEvents(ctx, c.Pod, c.Namespace)
+	if err != nil {
+		r.logger(c).Warn("Could not get the matched Events", logging.Err(err))
+	}
+	matched := []k8s.PodEvent{}
+	for _, event := range events {
+		if event.Matches(rule.Reason, rule.Message) {
+			matched = append(matched, event)
+		}
+	}
+	return matched
+}
+
+// auditRecord returns the audit record of the decision taken about a candidate
+func (r *Reconciler) auditRecord(c Candidate, decision string, events []k8s.PodEvent, result *k8s.Result, err error) audit.Record {
+	rec := audit.Record{
+		Time:      r.clock.Now().UTC(),
+		Cluster:   r.opts.Cluster,
+		Namespace: c.Namespace,
+		Pod:       c.Pod,
+		Rule:      c.Rule,
+		Events:    events,
+		Decision:  decision,
+		Action:    r.actions[c.rule].Name(),
+		DryRun:    r.opts.DryRun,
+	}
+	if c.Verdict != nil {
+		rec.Checks = c.Verdict.Checks
+		if c.Verdict.Reason != nil {
+			rec.Reason = c.Verdict.Reason.Error()
+		}
+		if pod := c.Verdict.Pod; pod != nil {
+			rec.UID = pod.UID
+			rec.Owner = pod.Owner()
+			rec.Node = pod.NodeName
+		}
+	}
+	if result != nil {
+		rec.Plan = result.Plan
+		rec.Outcome = result.Outcome
+	}
+	switch {
+	case decision == audit.DecisionDefer:
+		rec.Outcome = "deferred"
+		rec.Reason = err.Error()
+	case err != nil:
+		rec.Outcome = "failed"
+		rec.Response = err.Error()
+	case decision == audit.DecisionRemediate:
+		rec.Response = "OK"
+	}
+	return rec
+}
+
+// notification returns the notification of the outcome of a candidate
+// Message is the message of the latest matched Event
+func (r *Reconciler) notification(c Candidate, events []k8s.PodEvent, result *k8s.Result, err error) notify.Notification {
+	n := notify.Notification{
+		Time:      r.clock.Now().UTC(),
+		Cluster:   r.opts.Cluster,
+		Namespace: c.Namespace,
+		Pod:       c.Pod,
+		Rule:      c.Rule,
+		Action:    r.actions[c.rule].Name(),
+		Outcome:   "failed",
+		DryRun:    r.opts.DryRun,
+	}
+	if result != nil && err == nil {
+		n.Outcome = result.Outcome
+	}
+	if err != nil {
+		n.Error = err.Error()
+	}
+	if c.Verdict != nil && c.Verdict.Pod != nil {
+		n.Owner = c.Verdict.Pod.Owner()
+		n.Node = c.Verdict.Pod.NodeName
+	}
+	if len(events) > 0 {
+		n.Message = events[len(events)-1].Message
+	}
+	return n
+}
@@ -0,0 +1,181 @@
+package reconciler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	k8s "github.com/andreistefanciprian/pod-restarter-go/kubernetes"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
+)
+
+// Output formats of the reports
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+	OutputYAML  = "yaml"
+)
+
+// Report is the document written by the scan command
+type Report struct {
+	GeneratedAt time.Time   `json:"generatedAt"`
+	Candidates  []PodReport `json:"candidates"`
+}
+
+// PodReport describes a candidate Pod, the rule it matched and what would be done about it
+// Action is the planned remediation and is only set when the Pod passed all checks
+// Deferred is why the planned remediation would not run now (paused or outside the schedule windows)
+type PodReport struct {
+	Cluster   string            `json:"cluster,omitempty"`
+	Namespace string            `json:"namespace"`
+	Pod       string            `json:"pod"`
+	UID       types.UID         `json:"uid,omitempty"`
+	Node      string            `json:"node,omitempty"`
+	Owners    []k8s.Owner       `json:"owners,omitempty"`
+	Rule      string            `json:"rule"`
+	Events    []k8s.PodEvent    `json:"events"`
+	Checks    []k8s.CheckResult `json:"checks,omitempty"`
+	Restart   bool              `json:"restart"`
+	Reason    string            `json:"reason,omitempty"`
+	Action    *k8s.Plan         `json:"action,omitempty"`
+	Deferred  string            `json:"deferred,omitempty"`
+	Errors    []string          `json:"errors,omitempty"`
+}
+
+// ValidateOutput returns error if format is not a supported report format
+func ValidateOutput(format string) error {
+	switch format {
+	case OutputTable, OutputJSON, OutputYAML:
+		return nil
+	}
+	return fmt.Errorf("invalid output format %q: expected %s, %s or %s", format, OutputTable, OutputJSON, OutputYAML)
+}
+
+// Report describes every candidate with its owner chain, matched Events, check results and planned action
+func (r *Reconciler) Report(ctx context.Context, candidates []Candidate) *Report {
+	doc := &Report{
+		GeneratedAt: r.clock.Now().UTC(),
+		Candidates:  []PodReport{},
+	}
+	var paused error
+	if len(candidates) > 0 {
+		paused = r.pause(ctx)
+	}
+	for _, c := range candidates {
+		rule := r.cfg.Rules[c.rule]
+		pr := PodReport{
+			Cluster:   r.opts.Cluster,
+			Namespace: c.Namespace,
+			Pod:       c.Pod,
+			Rule:      rule.Name,
+			Events:    []k8s.PodEvent{},
+		}
+
+		events, err := r.client.PodEvents(ctx, c.Pod, c.Namespace)
+		if err != nil {
+			pr.Errors = append(pr.Errors, err.Error())
+		}
+		for _, event := range events {
+			if event.Matches(rule.Reason, rule.Message) {
+				pr.Events = append(pr.Events, event)
+			}
+		}
+
+		if c.Err != nil {
+			pr.Errors = append(pr.Errors, c.Err.Error())
+			doc.Candidates = append(doc.Candidates, pr)
+			continue
+		}
+		pr.Checks = c.Verdict.Checks
+		pr.Restart = c.Verdict.Restart
+		if c.Verdict.Reason != nil {
+			pr.Reason = c.Verdict.Reason.Error()
+		}
+
+		if pod := c.Verdict.Pod; pod != nil {
+			pr.UID = pod.UID
+			pr.Node = pod.NodeName
+			pr.Owners, err = r.client.OwnerChain(ctx, pod)
+			if err != nil {
+				pr.Errors = append(pr.Errors, err.Error())
+			}
+		}
+
+		// plan the action without executing it
+		if c.Verdict.Restart {
+			pr.Action, err = r.Plan(ctx, c)
+			if err != nil {
+				pr.Errors = append(pr.Errors, err.Error())
+			}
+			if deferral := r.deferral(paused, c.rule); deferral != nil {
+				pr.Deferred = deferral.Error()
+			}
+		}
+		doc.Candidates = append(doc.Candidates, pr)
+	}
+	return doc
+}
+
+// Failed returns true if the candidate could not be checked or its action could not be planned
+func (pr PodReport) Failed() bool {
+	return pr.Checks == nil || (pr.Restart && pr.Action == nil)
+}
+
+// WriteReport writes the report in the output format
+func WriteReport(w io.Writer, format string, doc *Report) error {
+	switch format {
+	case OutputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(doc)
+	case OutputYAML:
+		data, err := yaml.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	case OutputTable:
+		return writeTable(w, doc)
+	}
+	return ValidateOutput(format)
+}
+
+// writeTable writes a line per candidate with its verdict and planned action
+// The CLUSTER column is only written in multi-cluster mode
+func writeTable(w io.Writer, doc *Report) error {
+	var clusters bool
+	for _, pr := range doc.Candidates {
+		clusters = clusters || pr.Cluster != ""
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if clusters {
+		fmt.Fprint(tw, "CLUSTER\t")
+	}
+	fmt.Fprintln(tw, "NAMESPACE\tPOD\tRULE\tOWNER\tEVENTS\tVERDICT\tACTION\tREASON")
+	for _, pr := range doc.Candidates {
+		verdict, action, reason := "skip", "", pr.Reason
+		switch {
+		case pr.Action != nil && pr.Deferred != "":
+			verdict, action, reason = "deferred", pr.Action.Action+" "+pr.Action.Target, pr.Deferred
+		case pr.Action != nil:
+			verdict, action = "restart", pr.Action.Action+" "+pr.Action.Target
+		case pr.Failed():
+			verdict, reason = "error", strings.Join(pr.Errors, "; ")
+		}
+		owner := "<none>"
+		if len(pr.Owners) > 0 {
+			owner = pr.Owners[len(pr.Owners)-1].String()
+		}
+		if clusters {
+			fmt.Fprintf(tw, "%s\t", pr.Cluster)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\n", pr.Namespace, pr.Pod, pr.Rule, owner, len(pr.Events), verdict, action, reason)
+	}
+	return tw.Flush()
+}
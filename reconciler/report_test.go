@@ -0,0 +1,131 @@
+package reconciler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	k8s "github.com/andreistefanciprian/pod-restarter-go/kubernetes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+)
+
+func testReport() *Report {
+	eventTime := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	return &Report{
+		GeneratedAt: eventTime,
+		Candidates: []PodReport{
+			{
+				Namespace: "default",
+				Pod:       "foo-abc-123",
+				UID:       "uid1",
+				Node:      "node1",
+				Owners:    []k8s.Owner{{Kind: "ReplicaSet", Name: "foo-abc"}, {Kind: "Deployment", Name: "foo"}},
+				Rule:      "veth",
+				Events: []k8s.PodEvent{{
+					UID:            "uid1",
+					PodName:        "foo-abc-123",
+					PodNamespace:   "default",
+					EventType:      "Warning",
+					Reason:         "FailedCreatePodSandBox",
+					Message:        "container veth name provided (eth0) already exists",
+					FirstTimestamp: eventTime,
+					LastTimestamp:  eventTime,
+				}},
+				Checks: []k8s.CheckResult{
+					{Name: "exists", Outcome: k8s.CheckPassed},
+					{Name: k8s.CheckUnhealthy, Outcome: k8s.CheckPassed},
+				},
+				Restart: true,
+				Action:  &k8s.Plan{Action: k8s.ActionDelete, Target: "Pod/foo-abc-123", Description: "delete Pod default/foo-abc-123"},
+			},
+			{
+				Namespace: "default",
+				Pod:       "bar",
+				Rule:      "veth",
+				Events:    []k8s.PodEvent{},
+				Checks: []k8s.CheckResult{
+					{Name: "exists", Outcome: k8s.CheckPassed},
+					{Name: k8s.CheckHasOwner, Outcome: k8s.CheckFailed, Reason: k8s.ErrNoOwner},
+				},
+				Reason: k8s.ErrNoOwner.Error(),
+			},
+			{
+				Namespace: "default",
+				Pod:       "baz",
+				Rule:      "veth",
+				Events:    []k8s.PodEvent{},
+				Errors:    []string{errors.New("the server is currently unable to handle the request").Error()},
+			},
+		},
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	tests := map[string]struct {
+		format   string
+		validate func(t *testing.T, out string)
+	}{
+		"JSON": {
+			format: OutputJSON,
+			validate: func(t *testing.T, out string) {
+				var doc map[string]any
+				require.NoError(t, json.Unmarshal([]byte(out), &doc))
+				candidates := doc["candidates"].([]any)
+				require.Len(t, candidates, 3)
+				first := candidates[0].(map[string]any)
+				assert.Equal(t, "uid1", first["uid"])
+				assert.Equal(t, "node1", first["node"])
+				assert.Equal(t, "Deployment", first["owners"].([]any)[1].(map[string]any)["kind"])
+				assert.Equal(t, "2026-10-18T10:00:00Z", first["events"].([]any)[0].(map[string]any)["lastTimestamp"])
+				assert.Equal(t, "delete", first["action"].(map[string]any)["action"])
+				second := candidates[1].(map[string]any)
+				assert.Equal(t, "Pod does not have owner/controller", second["checks"].([]any)[1].(map[string]any)["reason"])
+				assert.NotContains(t, second, "action")
+			},
+		},
+		"YAML": {
+			format: OutputYAML,
+			validate: func(t *testing.T, out string) {
+				var doc map[string]any
+				require.NoError(t, yaml.Unmarshal([]byte(out), &doc))
+				candidates := doc["candidates"].([]any)
+				require.Len(t, candidates, 3)
+				assert.Equal(t, "Pod/foo-abc-123", candidates[0].(map[string]any)["action"].(map[string]any)["target"])
+				assert.Equal(t, "veth", candidates[1].(map[string]any)["rule"])
+				assert.Contains(t, out, "reason: FailedCreatePodSandBox")
+			},
+		},
+		"Table": {
+			format: OutputTable,
+			validate: func(t *testing.T, out string) {
+				lines := strings.Split(strings.TrimSpace(out), "\n")
+				require.Len(t, lines, 4)
+				assert.Equal(t, []string{"NAMESPACE", "POD", "RULE", "OWNER", "EVENTS", "VERDICT", "ACTION", "REASON"}, strings.Fields(lines[0]))
+				assert.Equal(t, []string{"default", "foo-abc-123", "veth", "Deployment/foo", "1", "restart", "delete", "Pod/foo-abc-123"}, strings.Fields(lines[1]))
+				assert.Contains(t, lines[2], "skip")
+				assert.Contains(t, lines[2], "Pod does not have owner/controller")
+				assert.Contains(t, lines[3], "error")
+				assert.Contains(t, lines[3], "unable to handle the request")
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			require.NoError(t, WriteReport(&out, tc.format, testReport()))
+			tc.validate(t, out.String())
+		})
+	}
+}
+
+func TestWriteReportInvalidFormat(t *testing.T) {
+	var out bytes.Buffer
+	assert.Error(t, WriteReport(&out, "xml", testReport()))
+	assert.Empty(t, out.String())
+}
@@ -0,0 +1,192 @@
+package reconciler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/andreistefanciprian/pod-restarter-go/config"
+	k8s "github.com/andreistefanciprian/pod-restarter-go/kubernetes"
+	"github.com/andreistefanciprian/pod-restarter-go/logging"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	testingclock "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/yaml"
+)
+
+// SimulatedRestart is a Pod that would have been remediated during a simulation, and when
+type SimulatedRestart struct {
+	Time      time.Time `json:"time"`
+	Namespace string    `json:"namespace"`
+	Pod       string    `json:"pod"`
+	Rule      string    `json:"rule"`
+	Action    *k8s.Plan `json:"action"`
+}
+
+// Simulate replays the recorded Events in a fake clientset, one polling interval at a time, starting at the oldest Event
+// Every cycle runs the real scan (GenerateToBeDeletedPodList and PodChecks) with the clock set to the cycle time
+// and only sees the Events and Pods recorded up to that time
+// Pods keep their recorded state, and a restarted Pod is removed so it is not restarted again
+// Pods deferred by the schedules are restarted by the first cycle they are allowed in, if it happens before the last Event
+func Simulate(ctx context.Context, cfg *config.Config, objects []runtime.Object, interval time.Duration, pageSize int64) ([]SimulatedRestart, error) {
+	if interval < time.Second {
+		return nil, fmt.Errorf("invalid polling interval %s", interval)
+	}
+
+	var events []*v1.Event
+	var pods []*v1.Pod
+	var others []runtime.Object
+	for _, obj := range objects {
+		switch o := obj.(type) {
+		case *v1.Event:
+			// Events created through the events.k8s.io API only have an EventTime
+			if o.LastTimestamp.IsZero() {
+				o.LastTimestamp = metav1.NewTime(eventTime(o))
+			}
+			// hand written Events may not have a UID, which the Event cursors rely on
+			if o.UID == "" {
+				o.UID = types.UID(o.Namespace + "/" + o.Name)
+			}
+			events = append(events, o)
+		case *v1.Pod:
+			pods = append(pods, o)
+		default:
+			others = append(others, o)
+		}
+	}
+	if len(events) == 0 {
+		return nil, errors.New("no Events recorded")
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].LastTimestamp.Before(&events[j].LastTimestamp)
+	})
+	sort.SliceStable(pods, func(i, j int) bool {
+		return pods[i].CreationTimestamp.Before(&pods[j].CreationTimestamp)
+	})
+
+	start := events[0].LastTimestamp.Time
+	clk := testingclock.NewFakeClock(start)
+	clientSet := fake.NewSimpleClientset(others...)
+	r, err := New(k8s.NewK8sClientFromClientSet(clientSet, pageSize, clk), cfg, clk, Options{DryRun: true, PollingInterval: interval})
+	if err != nil {
+		return nil, err
+	}
+
+	var restarts []SimulatedRestart
+	var nextEvent, nextPod int
+	for counter := 0; nextEvent < len(events); counter++ {
+		now := start.Add(time.Duration(counter) * interval)
+		clk.SetTime(now)
+
+		// record the Pods created and the Events seen up to now
+		for ; nextPod < len(pods) && !pods[nextPod].CreationTimestamp.Time.After(now); nextPod++ {
+			err = clientSet.Tracker().Add(pods[nextPod])
+			if err != nil {
+				return restarts, err
+			}
+		}
+		for ; nextEvent < len(events) && !events[nextEvent].LastTimestamp.Time.After(now); nextEvent++ {
+			err = clientSet.Tracker().Add(events[nextEvent])
+			if err != nil {
+				return restarts, err
+			}
+		}
+
+		candidates, err := r.Scan(ctx)
+		if err != nil {
+			return restarts, err
+		}
+		r.requeued = nil
+		for _, c := range candidates {
+			if c.Err != nil {
+				r.logger(c).Warn("Could not check Pod", logging.Err(c.Err))
+				continue
+			}
+			if !c.Verdict.Restart {
+				r.logger(c).Debug("Skipping Pod", "reason", c.Verdict.Reason)
+				continue
+			}
+			if deferral := r.deferral(nil, c.rule); deferral != nil {
+				r.logger(c).Debug("Deferring Pod", "reason", deferral)
+				r.inFlight.Release(c.Verdict.Pod.UID)
+				r.requeued = append(r.requeued, Candidate{Rule: c.Rule, Pod: c.Pod, Namespace: c.Namespace, rule: c.rule})
+				continue
+			}
+			plan, err := r.Plan(ctx, c)
+			if err != nil {
+				r.logger(c).Warn("Could not plan action", logging.Err(err))
+				continue
+			}
+			restarts = append(restarts, SimulatedRestart{
+				Time:      now,
+				Namespace: c.Namespace,
+				Pod:       c.Pod,
+				Rule:      c.Rule,
+				Action:    plan,
+			})
+			err = clientSet.CoreV1().Pods(c.Namespace).Delete(ctx, c.Pod, metav1.DeleteOptions{})
+			if err != nil {
+				return restarts, err
+			}
+		}
+
+		// skip the cycles without new Events, unless deferred Pods are waiting for their schedule
+		if nextEvent < len(events) && len(r.requeued) == 0 {
+			idle := int(events[nextEvent].LastTimestamp.Sub(now) / interval)
+			if idle > 1 {
+				counter += idle - 1
+			}
+		}
+	}
+	return restarts, nil
+}
+
+// eventTime returns the last time an Event was seen
+func eventTime(event *v1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	case !event.FirstTimestamp.IsZero():
+		return event.FirstTimestamp.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
+// WriteSimulation writes the simulated restarts in the output format
+func WriteSimulation(w io.Writer, format string, restarts []SimulatedRestart) error {
+	if restarts == nil {
+		restarts = []SimulatedRestart{}
+	}
+	switch format {
+	case OutputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(restarts)
+	case OutputYAML:
+		data, err := yaml.Marshal(restarts)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	case OutputTable:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "TIME\tNAMESPACE\tPOD\tRULE\tACTION\tTARGET")
+		for _, restart := range restarts {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				restart.Time.Format(time.RFC3339), restart.Namespace, restart.Pod, restart.Rule, restart.Action.Action, restart.Action.Target)
+		}
+		return tw.Flush()
+	}
+	return ValidateOutput(format)
+}
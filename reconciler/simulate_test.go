@@ -0,0 +1,112 @@
+package reconciler
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andreistefanciprian/pod-restarter-go/config"
+	k8s "github.com/andreistefanciprian/pod-restarter-go/kubernetes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var simulationStart = time.Date(2026, 10, 11, 10, 0, 0, 0, time.UTC)
+
+func recordedPod(name string, created time.Duration, phase v1.PodPhase) *v1.Pod {
+	controller := true
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "default",
+			UID:               types.UID(name + "-uid"),
+			CreationTimestamp: metav1.NewTime(simulationStart.Add(created)),
+			OwnerReferences:   []metav1.OwnerReference{{Kind: "ReplicaSet", Name: name + "-rs", Controller: &controller}},
+		},
+		Status: v1.PodStatus{Phase: phase},
+	}
+}
+
+func recordedEvent(pod, reason, message string, seen time.Duration) *v1.Event {
+	return &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pod + "." + seen.String(),
+			Namespace: "default",
+		},
+		InvolvedObject: v1.ObjectReference{Kind: "Pod", Name: pod, Namespace: "default", UID: types.UID(pod + "-uid")},
+		Reason:         reason,
+		Message:        message,
+		Type:           v1.EventTypeWarning,
+		LastTimestamp:  metav1.NewTime(simulationStart.Add(seen)),
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	const (
+		reason  = "FailedCreatePodSandBox"
+		message = "container veth name provided (eth0) already exists"
+	)
+	cfg := &config.Config{Rules: []config.Rule{{Name: "veth", Reason: reason, Message: message}}}
+	require.NoError(t, cfg.Validate())
+
+	objects := []runtime.Object{
+		recordedPod("foo", -time.Minute, v1.PodPending),
+		recordedEvent("foo", reason, message, 0),
+		// foo has already been restarted when this Event is seen
+		recordedEvent("foo", reason, message, 10*time.Second),
+		// bar is healthy
+		recordedPod("bar", -time.Minute, v1.PodRunning),
+		recordedEvent("bar", reason, message, 5*time.Minute),
+		// baz is created after the simulation starts
+		recordedPod("baz", 9*time.Minute, v1.PodPending),
+		recordedEvent("baz", reason, message, 10*time.Minute+5*time.Second),
+		// qux does not match the rule
+		recordedPod("qux", -time.Minute, v1.PodPending),
+		recordedEvent("qux", "BackOff", "Back-off pulling image", 2*time.Minute),
+	}
+
+	restarts, err := Simulate(context.TODO(), cfg, objects, 30*time.Second, 0)
+	require.NoError(t, err)
+	require.Len(t, restarts, 2)
+
+	assert.Equal(t, "foo", restarts[0].Pod)
+	assert.Equal(t, simulationStart, restarts[0].Time)
+	assert.Equal(t, "veth", restarts[0].Rule)
+	assert.Equal(t, k8s.ActionDelete, restarts[0].Action.Action)
+	assert.Equal(t, "baz", restarts[1].Pod)
+	assert.Equal(t, simulationStart.Add(10*time.Minute+30*time.Second), restarts[1].Time)
+}
+
+func TestSimulateWithoutEvents(t *testing.T) {
+	cfg := &config.Config{Rules: []config.Rule{{Reason: "BackOff"}}}
+	require.NoError(t, cfg.Validate())
+
+	_, err := Simulate(context.TODO(), cfg, []runtime.Object{recordedPod("foo", 0, v1.PodPending)}, 30*time.Second, 0)
+	assert.Error(t, err)
+}
+
+func TestWriteSimulation(t *testing.T) {
+	restarts := []SimulatedRestart{{
+		Time:      simulationStart,
+		Namespace: "default",
+		Pod:       "foo",
+		Rule:      "veth",
+		Action:    &k8s.Plan{Action: k8s.ActionDelete, Target: "Pod/foo"},
+	}}
+
+	var out bytes.Buffer
+	require.NoError(t, WriteSimulation(&out, OutputTable, restarts))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Equal(t, []string{"2026-10-11T10:00:00Z", "default", "foo", "veth", "delete", "Pod/foo"}, strings.Fields(lines[1]))
+
+	out.Reset()
+	require.NoError(t, WriteSimulation(&out, OutputJSON, nil))
+	assert.Equal(t, "[]\n", out.String())
+}
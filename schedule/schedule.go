@@ -0,0 +1,88 @@
+// Package schedule decides when remediations are allowed, from cron-like maintenance and blackout windows
+package schedule
+
+import (
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MaxWindowDuration is the longest window; longer freezes are better handled by pausing pod-restarter
+const MaxWindowDuration = 31 * 24 * time.Hour
+
+// Window opens every time Cron matches and stays open for Duration (eg: "0 18 * * FRI" for 63h is a weekend freeze)
+type Window struct {
+	Cron     string          `json:"cron"`
+	Duration metav1.Duration `json:"duration"`
+
+	cron *cron
+}
+
+// Schedule allows remediations inside its Allow windows (always, when there are none) unless a Deny window is open
+// Windows are evaluated in Timezone, a IANA time zone name (UTC by default)
+type Schedule struct {
+	Timezone string   `json:"timezone,omitempty"`
+	Allow    []Window `json:"allow,omitempty"`
+	Deny     []Window `json:"deny,omitempty"`
+
+	loc *time.Location
+}
+
+// Validate parses the time zone and the cron expressions of the windows
+func (s *Schedule) Validate() error {
+	var err error
+	s.loc, err = time.LoadLocation(s.Timezone)
+	if err != nil {
+		return fmt.Errorf("schedule: invalid timezone %q: %w", s.Timezone, err)
+	}
+	for _, windows := range [][]Window{s.Allow, s.Deny} {
+		for i := range windows {
+			err = windows[i].validate()
+			if err != nil {
+				return fmt.Errorf("schedule: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// validate parses the cron expression of the window
+func (w *Window) validate() error {
+	d := w.Duration.Duration
+	if d < time.Minute || d > MaxWindowDuration {
+		return fmt.Errorf("window %q: duration must be between 1m and %s: %s", w.Cron, MaxWindowDuration, d)
+	}
+	var err error
+	w.cron, err = parseCron(w.Cron)
+	return err
+}
+
+// Allows returns nil if remediations are allowed at t, or the reason they are not
+// A nil Schedule always allows remediations
+func (s *Schedule) Allows(t time.Time) error {
+	if s == nil {
+		return nil
+	}
+	t = t.In(s.loc)
+	for _, w := range s.Deny {
+		if w.open(t) {
+			return fmt.Errorf("blackout window %q for %s (%s) is open", w.Cron, w.Duration.Duration, s.loc)
+		}
+	}
+	if len(s.Allow) == 0 {
+		return nil
+	}
+	for _, w := range s.Allow {
+		if w.open(t) {
+			return nil
+		}
+	}
+	return fmt.Errorf("outside the maintenance windows (%s)", s.loc)
+}
+
+// open returns true if the window opened less than Duration before t, ie: the cron expression matched a minute since t - Duration
+func (w Window) open(t time.Time) bool {
+	_, ok := w.cron.latest(t, t.Add(-w.Duration.Duration))
+	return ok
+}
@@ -0,0 +1,265 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseCron(t *testing.T) {
+	testCases := []struct {
+		testName string
+		expr     string
+		times    map[string]bool
+		wantErr  bool
+	}{
+		{
+			testName: "Every minute",
+			expr:     "* * * * *",
+			times:    map[string]bool{"2026-10-18T10:07:00Z": true},
+		},
+		{
+			testName: "Named day of week",
+			expr:     "0 18 * * FRI",
+			times: map[string]bool{
+				"2026-10-16T18:00:00Z": true,  // Friday
+				"2026-10-16T18:01:00Z": false, // Friday
+				"2026-10-17T18:00:00Z": false, // Saturday
+			},
+		},
+		{
+			testName: "Sunday is 7",
+			expr:     "0 0 * * 7",
+			times: map[string]bool{
+				"2026-10-18T00:00:00Z": true, // Sunday
+				"2026-10-19T00:00:00Z": false,
+			},
+		},
+		{
+			testName: "Ranges, lists and steps",
+			expr:     "*/15 9-17 * JAN,OCT-DEC MON-FRI",
+			times: map[string]bool{
+				"2026-10-19T09:45:00Z": true,
+				"2026-10-19T09:50:00Z": false,
+				"2026-10-19T18:00:00Z": false,
+				"2026-06-15T10:00:00Z": false,
+			},
+		},
+		{
+			testName: "Restricted day of month or day of week",
+			expr:     "0 0 1 * MON",
+			times: map[string]bool{
+				"2026-10-01T00:00:00Z": true, // Thursday
+				"2026-10-19T00:00:00Z": true, // Monday
+				"2026-10-20T00:00:00Z": false,
+			},
+		},
+		{
+			testName: "Missing field",
+			expr:     "0 18 * *",
+			wantErr:  true,
+		},
+		{
+			testName: "Out of range",
+			expr:     "60 * * * *",
+			wantErr:  true,
+		},
+		{
+			testName: "Invalid step",
+			expr:     "*/0 * * * *",
+			wantErr:  true,
+		},
+		{
+			testName: "Reversed range",
+			expr:     "* 17-9 * * *",
+			wantErr:  true,
+		},
+		{
+			testName: "Unknown name",
+			expr:     "* * * * FUN",
+			wantErr:  true,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			c, err := parseCron(test.expr)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			for ts, want := range test.times {
+				tm, err := time.Parse(time.RFC3339, ts)
+				require.NoError(t, err)
+				assert.Equal(t, want, c.matches(tm), ts)
+			}
+		})
+	}
+}
+
+func window(cron string, d time.Duration) Window {
+	return Window{Cron: cron, Duration: metav1.Duration{Duration: d}}
+}
+
+func TestAllows(t *testing.T) {
+	testCases := []struct {
+		testName string
+		schedule *Schedule
+		time     string
+		wantErr  string
+	}{
+		{
+			testName: "Nil schedule",
+			time:     "2026-10-17T12:00:00Z",
+		},
+		{
+			testName: "Inside allow window",
+			schedule: &Schedule{Allow: []Window{window("0 9 * * MON-FRI", 8*time.Hour)}},
+			time:     "2026-10-19T16:59:00Z",
+		},
+		{
+			testName: "Outside allow window",
+			schedule: &Schedule{Allow: []Window{window("0 9 * * MON-FRI", 8*time.Hour)}},
+			time:     "2026-10-19T17:00:00Z",
+			wantErr:  "outside the maintenance windows (UTC)",
+		},
+		{
+			testName: "Open deny window",
+			schedule: &Schedule{Deny: []Window{window("0 18 * * FRI", 63*time.Hour)}},
+			time:     "2026-10-19T08:59:00Z",
+			wantErr:  `blackout window "0 18 * * FRI" for 63h0m0s (UTC) is open`,
+		},
+		{
+			testName: "Closed deny window",
+			schedule: &Schedule{Deny: []Window{window("0 18 * * FRI", 63*time.Hour)}},
+			time:     "2026-10-19T09:00:00Z",
+		},
+		{
+			testName: "Deny window wins over allow window",
+			schedule: &Schedule{
+				Allow: []Window{window("0 0 * * *", 24*time.Hour)},
+				Deny:  []Window{window("0 12 * * *", time.Hour)},
+			},
+			time:    "2026-10-19T12:30:00Z",
+			wantErr: "blackout window",
+		},
+		{
+			testName: "Windows are evaluated in the time zone",
+			schedule: &Schedule{Timezone: "Australia/Sydney", Allow: []Window{window("0 2 * * *", 2*time.Hour)}},
+			// 02:30 in Sydney (AEDT, UTC+11)
+			time: "2026-10-18T15:30:00Z",
+		},
+		{
+			testName: "Outside window in the time zone",
+			schedule: &Schedule{Timezone: "Australia/Sydney", Allow: []Window{window("0 2 * * *", 2*time.Hour)}},
+			time:     "2026-10-18T02:30:00Z",
+			wantErr:  "outside the maintenance windows (Australia/Sydney)",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			if test.schedule != nil {
+				require.NoError(t, test.schedule.Validate())
+			}
+			tm, err := time.Parse(time.RFC3339, test.time)
+			require.NoError(t, err)
+			err = test.schedule.Allows(tm)
+			if test.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.wantErr)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		testName string
+		schedule Schedule
+	}{
+		{
+			testName: "Unknown time zone",
+			schedule: Schedule{Timezone: "Mars/Olympus_Mons"},
+		},
+		{
+			testName: "Invalid cron",
+			schedule: Schedule{Allow: []Window{window("every friday", time.Hour)}},
+		},
+		{
+			testName: "Too short",
+			schedule: Schedule{Deny: []Window{window("0 18 * * FRI", time.Second)}},
+		},
+		{
+			testName: "Too long",
+			schedule: Schedule{Deny: []Window{window("0 18 * * FRI", MaxWindowDuration+time.Hour)}},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			assert.Error(t, test.schedule.Validate())
+		})
+	}
+}
+
+func TestCronLatest(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	require.NoError(t, err)
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	require.NoError(t, err)
+
+	// latestByMinute checks every minute since start, like cron would have fired
+	latestByMinute := func(c *cron, t, start time.Time) (time.Time, bool) {
+		for m := t.Truncate(time.Minute); m.After(start); m = m.Add(-time.Minute) {
+			if c.matches(m.In(t.Location())) {
+				return m, true
+			}
+		}
+		return time.Time{}, false
+	}
+
+	exprs := []string{"* * * * *", "0 18 * * FRI", "*/15 9-17 * * MON-FRI", "30 2 * * *", "0 0 1 * *", "0 12 29 2 *", "59 23 31 * SUN", "5,55 */5 1-7 */2 *"}
+	locations := []*time.Location{time.UTC, london, kolkata}
+	durations := []time.Duration{time.Minute, 90 * time.Minute, 63 * time.Hour, MaxWindowDuration}
+	// around the Europe/London DST changes and a leap day
+	times := []time.Time{
+		time.Date(2026, 3, 29, 1, 30, 0, 0, time.UTC),
+		time.Date(2026, 3, 29, 2, 45, 10, 0, time.UTC),
+		time.Date(2026, 10, 25, 1, 15, 0, 0, time.UTC),
+		time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC),
+		time.Date(2028, 3, 1, 0, 0, 30, 0, time.UTC),
+	}
+
+	for _, expr := range exprs {
+		c, err := parseCron(expr)
+		require.NoError(t, err)
+		for _, loc := range locations {
+			for _, d := range durations {
+				for _, now := range times {
+					now = now.In(loc)
+					want, wantOK := latestByMinute(c, now, now.Add(-d))
+					got, ok := c.latest(now, now.Add(-d))
+					require.Equal(t, wantOK, ok, "%q in %s for %s at %s", expr, loc, d, now)
+					assert.True(t, want.Equal(got), "%q in %s for %s at %s: want %s, got %s", expr, loc, d, now, want, got)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkAllows(b *testing.B) {
+	s := &Schedule{Deny: []Window{{Cron: "0 0 1 1 *", Duration: metav1.Duration{Duration: MaxWindowDuration}}}}
+	require.NoError(b, s.Validate())
+	now := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = s.Allows(now)
+	}
+}